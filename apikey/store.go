@@ -0,0 +1,275 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "apikey"})
+
+// Key is an API key and the access it grants. The counters of a key are kept under its name,
+// so that the key itself can be rotated.
+type Key struct {
+	Name           string   `mapstructure:"name"`
+	Key            string   `mapstructure:"key"`
+	Namespaces     []string `mapstructure:"namespaces"`     // all the namespaces if empty
+	RequestsPerSec float64  `mapstructure:"requestsPerSec"` // the default API key quota if not positive
+	Burst          float64  `mapstructure:"burst"`
+}
+
+// AllowsMethod returns whether the namespace of the method, such as eth for eth_call, is granted to the key
+func (k *Key) AllowsMethod(method string) bool {
+	if len(k.Namespaces) == 0 {
+		return true
+	}
+	namespace := strings.SplitN(method, "_", 2)[0]
+	for _, allowed := range k.Namespaces {
+		if allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Usage is a snapshot of the counters of a key
+type Usage struct {
+	Name         string
+	Requests     uint64
+	ComputeUnits uint64
+	Rejected     uint64
+}
+
+type counters struct {
+	requests     uint64 // accessed atomically
+	computeUnits uint64 // accessed atomically
+	rejected     uint64 // accessed atomically
+}
+
+// Store holds the API keys given in the config and in the optional key file. The key file is
+// checked for changes every reload interval, and reloaded without a restart. It is safe for
+// concurrent use.
+type Store struct {
+	configKeys     []Key
+	file           string
+	reloadInterval time.Duration
+
+	mu      sync.RWMutex
+	keys    map[string]*Key // by key
+	modTime time.Time       // of the key file when last loaded
+
+	countersMu sync.Mutex
+	counters   map[string]*counters // by key name
+
+	// Life cycle
+	wg     *sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewStore creates a store with the given keys, and the keys of the given file if it is not empty
+func NewStore(configKeys []Key, file string, reloadInterval time.Duration) (*Store, error) {
+	s := &Store{
+		configKeys:     configKeys,
+		file:           file,
+		reloadInterval: reloadInterval,
+		counters:       make(map[string]*counters),
+		wg:             &sync.WaitGroup{},
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start kicks off the loop which reloads the key file.
+func (s *Store) Start(ctx context.Context) {
+	c, cancel := context.WithCancel(ctx)
+	s.ctx = c
+	s.cancel = cancel
+
+	if s.file != "" && s.reloadInterval > 0 {
+		s.wg.Add(1)
+		go s.mainLoop()
+	}
+}
+
+// Stop notifies the loop to stop without blocking.
+func (s *Store) Stop() {
+	s.cancel()
+}
+
+// Wait blocks until the loop stops.
+func (s *Store) Wait() {
+	s.wg.Wait()
+}
+
+// Lookup returns the key with the given value, a nil store has no key
+func (s *Store) Lookup(key string) (*Key, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, found := s.keys[key]
+	return k, found
+}
+
+// Record counts a call served for the key with the given name
+func (s *Store) Record(name string, computeUnits uint64) {
+	c := s.countersOf(name)
+	atomic.AddUint64(&c.requests, 1)
+	atomic.AddUint64(&c.computeUnits, computeUnits)
+}
+
+// RecordRejected counts a call of the key with the given name rejected for exceeding its quota
+func (s *Store) RecordRejected(name string) {
+	atomic.AddUint64(&s.countersOf(name).rejected, 1)
+}
+
+// Usage returns the counters of the keys, by name
+func (s *Store) Usage() []Usage {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	usage := make([]Usage, 0, len(s.counters))
+	for name, c := range s.counters {
+		usage = append(usage, Usage{
+			Name:         name,
+			Requests:     atomic.LoadUint64(&c.requests),
+			ComputeUnits: atomic.LoadUint64(&c.computeUnits),
+			Rejected:     atomic.LoadUint64(&c.rejected),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return usage
+}
+
+var (
+	apikeyRequests = prometheus.NewDesc("adaptor_apikey_requests_total",
+		"Calls served, by API key name.", []string{"key"}, nil)
+	apikeyComputeUnits = prometheus.NewDesc("adaptor_apikey_compute_units_total",
+		"Compute units of the calls served, by API key name.", []string{"key"}, nil)
+	apikeyRejected = prometheus.NewDesc("adaptor_apikey_rejected_total",
+		"Calls rejected for exceeding the quota or namespaces of the key, by API key name.", []string{"key"}, nil)
+)
+
+// usageCollector reads the counters of the keys each time the metrics are scraped
+type usageCollector struct {
+	store *Store
+}
+
+func (c usageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- apikeyRequests
+	ch <- apikeyComputeUnits
+	ch <- apikeyRejected
+}
+
+func (c usageCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, usage := range c.store.Usage() {
+		ch <- prometheus.MustNewConstMetric(apikeyRequests, prometheus.CounterValue, float64(usage.Requests), usage.Name)
+		ch <- prometheus.MustNewConstMetric(apikeyComputeUnits, prometheus.CounterValue, float64(usage.ComputeUnits), usage.Name)
+		ch <- prometheus.MustNewConstMetric(apikeyRejected, prometheus.CounterValue, float64(usage.Rejected), usage.Name)
+	}
+}
+
+// ExportMetrics registers the counters of the keys as metrics, it must be called once at most
+func (s *Store) ExportMetrics() {
+	prometheus.MustRegister(usageCollector{store: s})
+}
+
+func (s *Store) countersOf(name string) *counters {
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+
+	c, found := s.counters[name]
+	if !found {
+		c = &counters{}
+		s.counters[name] = c
+	}
+	return c
+}
+
+func (s *Store) mainLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(s.file)
+			if err != nil {
+				logger.Warnf("Failed to check the key file %v: %v", s.file, err)
+				continue
+			}
+			s.mu.RLock()
+			changed := !info.ModTime().Equal(s.modTime)
+			s.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := s.load(); err != nil {
+				logger.Warnf("Failed to reload the key file %v, keeping the previous keys: %v", s.file, err)
+			}
+		}
+	}
+}
+
+// load replaces the keys with the ones of the config and of the key file
+func (s *Store) load() error {
+	keys := append([]Key{}, s.configKeys...)
+
+	var modTime time.Time
+	if s.file != "" {
+		info, err := os.Stat(s.file)
+		if err != nil {
+			return err
+		}
+		modTime = info.ModTime()
+
+		v := viper.New()
+		v.SetConfigFile(s.file)
+		if err := v.ReadInConfig(); err != nil {
+			return err
+		}
+		fileKeys := []Key{}
+		if err := v.UnmarshalKey("keys", &fileKeys); err != nil {
+			return err
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	byKey := make(map[string]*Key)
+	for i := range keys {
+		k := &keys[i]
+		if k.Key == "" || k.Name == "" {
+			return fmt.Errorf("API key %v: both the name and the key must be set", i)
+		}
+		if _, found := byKey[k.Key]; found {
+			return fmt.Errorf("API key %v: duplicate key", k.Name)
+		}
+		byKey[k.Key] = k
+	}
+
+	s.mu.Lock()
+	s.keys = byKey
+	s.modTime = modTime
+	s.mu.Unlock()
+
+	logger.Infof("Loaded %v API keys", len(byKey))
+	return nil
+}
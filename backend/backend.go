@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+	trpc "github.com/scripttoken/script/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "backend"})
+
+// accountNotFoundError is part of the error the Script node returns for an address which has no
+// account yet
+const accountNotFoundError = "is not found"
+
+// ErrEmptyBlock is returned when the Script node has no finalized block for the query
+var ErrEmptyBlock = errors.New("empty block")
+
+// ScriptBackend is the typed interface the RPC handlers use to talk to a Script node.
+// Every method honors the deadline and the cancellation of the context it receives.
+type ScriptBackend interface {
+	GetStatus(ctx context.Context) (*trpc.GetStatusResult, error)
+	GetVersion(ctx context.Context) (*trpc.GetVersionResult, error)
+
+	GetAccount(ctx context.Context, address string, height tcommon.JSONUint64, preview bool) (*types.Account, error)
+	GetCode(ctx context.Context, address string, height tcommon.JSONUint64) (string, error)
+	GetStorageAt(ctx context.Context, address string, storagePosition string, height tcommon.JSONUint64) (string, error)
+
+	GetBlock(ctx context.Context, hash tcommon.Hash) (*common.ScriptGetBlockResultInner, error)
+	GetBlockByHeight(ctx context.Context, height tcommon.JSONUint64) (*common.ScriptGetBlockResultInner, error)
+	GetBlocksByRange(ctx context.Context, start tcommon.JSONUint64, end tcommon.JSONUint64) (common.ScriptGetBlocksResult, error)
+	GetTransaction(ctx context.Context, hash string) (*trpc.GetTransactionResult, error)
+
+	CallSmartContract(ctx context.Context, sctxBytes []byte) (*trpc.CallSmartContractResult, error)
+	BroadcastRawTransactionAsync(ctx context.Context, txBytes string) (string, error)
+	BroadcastRawEthTransactionAsync(ctx context.Context, txBytes string) (string, error)
+
+	// RawCall invokes a Script RPC method which has no typed wrapper, e.g. an optional
+	// tracing method, and returns its raw JSON result
+	RawCall(ctx context.Context, method string, args interface{}) (json.RawMessage, error)
+}
+
+// GetCurrentHeight returns the latest finalized height of the Script chain
+func GetCurrentHeight(ctx context.Context, b ScriptBackend) (height tcommon.JSONUint64, err error) {
+	status, err := b.GetStatus(ctx)
+	if err != nil {
+		return height, err
+	}
+	return status.LatestFinalizedBlockHeight, nil
+}
+
+// GetEthChainID returns the Ethereum chain ID that the Script chain maps to
+func GetEthChainID(ctx context.Context, b ScriptBackend) (uint64, error) {
+	status, err := b.GetStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+	blockHeight := uint64(status.LatestFinalizedBlockHeight)
+	return types.MapChainID(status.ChainID, blockHeight).Uint64(), nil
+}
+
+// GetSeqByAddress returns the sequence the next transaction sent from the given address should use.
+// An account the Script node does not know yet sends its first transaction with sequence 1.
+func GetSeqByAddress(ctx context.Context, b ScriptBackend, address tcommon.Address) (sequence uint64, err error) {
+	account, err := b.GetAccount(ctx, address.String(), 0, false)
+	if IsAccountNotFound(err) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	sequence = account.Sequence + 1
+
+	return sequence, nil
+}
+
+// IsAccountNotFound tells whether the error is the one the Script node returns for an address
+// which has no account yet
+func IsAccountNotFound(err error) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) && strings.Contains(rpcErr.Err.Message, accountNotFoundError)
+}
@@ -0,0 +1,53 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+	rpcc "github.com/ybbus/jsonrpc"
+)
+
+// mockBackend answers GetAccount with a canned account or error, the other methods of
+// ScriptBackend are not used by the tests and panic
+type mockBackend struct {
+	ScriptBackend
+
+	account *types.Account
+	err     error
+}
+
+func (b *mockBackend) GetAccount(ctx context.Context, address string, height tcommon.JSONUint64, preview bool) (*types.Account, error) {
+	return b.account, b.err
+}
+
+func TestGetSeqByAddress(t *testing.T) {
+	transportErr := &TransportError{Method: "script.GetAccount", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name    string
+		backend *mockBackend
+		want    uint64
+		wantErr error
+	}{
+		{"existing account", &mockBackend{account: &types.Account{Sequence: 7}}, 8, nil},
+		{"unknown account", &mockBackend{err: &RPCError{Method: "script.GetAccount",
+			Err: &rpcc.RPCError{Code: -32000, Message: "Account with address 0x01 is not found"}}}, 1, nil},
+		{"other rpc error", &mockBackend{err: &RPCError{Method: "script.GetAccount",
+			Err: &rpcc.RPCError{Code: -32000, Message: "internal error"}}}, 0, errors.New("")},
+		{"transport error", &mockBackend{err: transportErr}, 0, transportErr},
+	}
+
+	for _, test := range tests {
+		got, err := GetSeqByAddress(context.Background(), test.backend, tcommon.HexToAddress("0x01"))
+		if (err != nil) != (test.wantErr != nil) {
+			t.Errorf("%v: GetSeqByAddress() error = %v, wantErr %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err == nil && got != test.want {
+			t.Errorf("%v: GetSeqByAddress() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
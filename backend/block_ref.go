@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+)
+
+// ResolveBlockRef maps the block parameter to a Script height. Script blocks are final once
+// finalized, so "latest", "pending", "safe" and "finalized" all refer to the latest finalized
+// block, in which case isLatest is true. So do the heights above it, which the clients may have
+// read from a node slightly ahead. Block hashes are resolved through script.GetBlock.
+func ResolveBlockRef(ctx context.Context, b ScriptBackend, ref *common.BlockRef) (height tcommon.JSONUint64, isLatest bool, err error) {
+	if ref == nil {
+		return 0, true, nil
+	}
+
+	if ref.Hash != nil {
+		block, err := b.GetBlock(ctx, *ref.Hash)
+		if err != nil || block == nil {
+			return 0, false, fmt.Errorf("block %v not found", ref.Hash.Hex())
+		}
+		if ref.RequireCanonical && !block.Status.IsFinalized() {
+			return 0, false, fmt.Errorf("block %v is not canonical", ref.Hash.Hex())
+		}
+		height = block.Height
+	} else if ref.Number != nil {
+		height = *ref.Number
+	} else if ref.Tag == "earliest" {
+		height = common.GetHeightByTag("earliest")
+	} else {
+		return 0, true, nil
+	}
+
+	currentHeight, err := GetCurrentHeight(ctx, b)
+	if err != nil {
+		return 0, false, err
+	}
+	if height >= currentHeight {
+		return currentHeight, true, nil
+	}
+	return height, false, nil
+}
+
+// ResolveStateHeight maps the block parameter to the height argument of script.GetAccount,
+// script.GetCode and script.GetStorageAt, where 0 is interpreted as the latest height
+func ResolveStateHeight(ctx context.Context, b ScriptBackend, ref *common.BlockRef) (tcommon.JSONUint64, error) {
+	height, isLatest, err := ResolveBlockRef(ctx, b, ref)
+	if err != nil || isLatest {
+		return 0, err
+	}
+	return height, nil
+}
@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/cache"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+)
+
+// heightIndexEntrySize is the approximate footprint of a height -> hash entry of the cache
+const heightIndexEntrySize = 64
+
+// CachingBackend serves the finalized blocks from an in-process LRU cache, and forwards
+// everything else to the wrapped backend. Finalized blocks are immutable, so they never
+// need to be invalidated. The blocks are indexed by hash, and their hashes by height.
+type CachingBackend struct {
+	ScriptBackend
+
+	blocks *cache.LRU
+}
+
+var _ ScriptBackend = (*CachingBackend)(nil)
+
+// NewCachingBackend wraps b with a block cache holding at most maxBytes of blocks
+func NewCachingBackend(b ScriptBackend, maxBytes int64) *CachingBackend {
+	return &CachingBackend{
+		ScriptBackend: b,
+		blocks:        cache.NewLRU("script_blocks", maxBytes),
+	}
+}
+
+func (c *CachingBackend) GetBlock(ctx context.Context, hash tcommon.Hash) (*common.ScriptGetBlockResultInner, error) {
+	if block, ok := c.blocks.Get(hash); ok {
+		return block.(*common.ScriptGetBlockResultInner), nil
+	}
+
+	block, err := c.ScriptBackend.GetBlock(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.add(block)
+	return block, nil
+}
+
+func (c *CachingBackend) GetBlockByHeight(ctx context.Context, height tcommon.JSONUint64) (*common.ScriptGetBlockResultInner, error) {
+	if block := c.getByHeight(height); block != nil {
+		return block, nil
+	}
+
+	block, err := c.ScriptBackend.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	c.add(block)
+	return block, nil
+}
+
+// GetBlocksByRange serves the range from the cache only if all the blocks are cached,
+// otherwise the whole range is queried and cached
+func (c *CachingBackend) GetBlocksByRange(ctx context.Context, start tcommon.JSONUint64, end tcommon.JSONUint64) (common.ScriptGetBlocksResult, error) {
+	result := common.ScriptGetBlocksResult{}
+	for height := start; height <= end && height >= start; height++ {
+		block := c.getByHeight(height)
+		if block == nil {
+			result = nil
+			break
+		}
+		result = append(result, block)
+	}
+	if result != nil {
+		return result, nil
+	}
+
+	result, err := c.ScriptBackend.GetBlocksByRange(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range result {
+		c.add(block)
+	}
+	return result, nil
+}
+
+func (c *CachingBackend) getByHeight(height tcommon.JSONUint64) *common.ScriptGetBlockResultInner {
+	hash, ok := c.blocks.Get(height)
+	if !ok {
+		return nil
+	}
+	block, ok := c.blocks.Get(hash)
+	if !ok {
+		return nil
+	}
+	return block.(*common.ScriptGetBlockResultInner)
+}
+
+// add caches the block if it is finalized, the blocks which may still change are not cached
+func (c *CachingBackend) add(block *common.ScriptGetBlockResultInner) {
+	if block == nil || !block.Status.IsFinalized() {
+		return
+	}
+
+	blockBytes, err := json.Marshal(block)
+	if err != nil {
+		return
+	}
+	c.blocks.Add(block.Hash, block, int64(len(blockBytes)))
+	c.blocks.Add(block.Height, block.Hash, heightIndexEntrySize)
+}
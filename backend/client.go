@@ -0,0 +1,304 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/tracing"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+	trpc "github.com/scripttoken/script/rpc"
+	"github.com/spf13/viper"
+	rpcc "github.com/ybbus/jsonrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcc.RPCError  `json:"error"`
+}
+
+// ScriptClient implements ScriptBackend on top of the JSON-RPC interface of a Script node.
+// It is safe for concurrent use and is meant to be shared by all the handlers, so that
+// the connections to the node are kept alive and reused instead of re-dialed per call.
+type ScriptClient struct {
+	nextID         uint64 // accessed atomically, keep it 64-bit aligned
+	endpoint       string
+	httpClient     *http.Client
+	callTimeout    time.Duration
+	methodTimeouts map[string]time.Duration // keyed by the lower-cased method name without the "script." prefix
+}
+
+var _ ScriptBackend = (*ScriptClient)(nil)
+
+// NewScriptClient creates a client for the Script RPC endpoint with a pooled keep-alive transport
+func NewScriptClient(endpoint string) *ScriptClient {
+	maxIdleConns := viper.GetInt(common.CfgScriptRPCMaxIdleConns)
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConns,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	methodTimeouts := make(map[string]time.Duration)
+	for method := range viper.GetStringMap(common.CfgScriptRPCMethodTimeoutSecs) {
+		timeoutSecs := viper.GetInt64(common.CfgScriptRPCMethodTimeoutSecs + "." + method)
+		methodTimeouts[strings.ToLower(method)] = time.Duration(timeoutSecs) * time.Second
+	}
+
+	return &ScriptClient{
+		endpoint:       endpoint,
+		httpClient:     &http.Client{Transport: transport},
+		callTimeout:    time.Duration(viper.GetInt64(common.CfgScriptRPCTimeoutSecs)) * time.Second,
+		methodTimeouts: methodTimeouts,
+	}
+}
+
+// timeoutFor returns the deadline of a single call to the given method
+func (c *ScriptClient) timeoutFor(method string) time.Duration {
+	if timeout, ok := c.methodTimeouts[strings.ToLower(strings.TrimPrefix(method, "script."))]; ok {
+		return timeout
+	}
+	return c.callTimeout
+}
+
+// call invokes the given Script RPC method and returns the raw JSON result. The request is
+// bounded by the deadline of ctx, and by the timeout of the method if that comes earlier.
+func (c *ScriptClient) call(ctx context.Context, method string, args interface{}) (json.RawMessage, error) {
+	ctx, span := tracing.StartSpan(ctx, method, trace.SpanKindClient,
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("rpc.method", method))
+	defer span.End()
+
+	start := time.Now()
+	result, err := c.roundTrip(ctx, method, args)
+	observeUpstreamCall(method, start, err)
+	tracing.SetError(span, err)
+	return result, err
+}
+
+func (c *ScriptClient) roundTrip(ctx context.Context, method string, args interface{}) (json.RawMessage, error) {
+	if timeout := c.timeoutFor(method); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	reqBytes, err := json.Marshal(&rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&c.nextID, 1),
+		Method:  method,
+		Params:  []interface{}{args},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	tracing.Inject(ctx, req.Header)
+
+	httpRes, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &TransportError{Method: method, Err: err}
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, httpRes.Body) // drain the body so that the connection can be reused
+		httpRes.Body.Close()
+	}()
+
+	rpcRes := rpcResponse{}
+	if err := json.NewDecoder(httpRes.Body).Decode(&rpcRes); err != nil {
+		return nil, &TransportError{Method: method, Err: fmt.Errorf("%v, http status: %v", err, httpRes.Status)}
+	}
+	if rpcRes.Error != nil {
+		return nil, &RPCError{Method: method, Err: rpcRes.Error}
+	}
+
+	return rpcRes.Result, nil
+}
+
+func (c *ScriptClient) GetStatus(ctx context.Context) (*trpc.GetStatusResult, error) {
+	jsonBytes, err := c.call(ctx, "script.GetStatus", trpc.GetStatusArgs{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &trpc.GetStatusResult{}
+	if err := decodeResult("script.GetStatus", jsonBytes, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ScriptClient) GetVersion(ctx context.Context) (*trpc.GetVersionResult, error) {
+	jsonBytes, err := c.call(ctx, "script.GetVersion", trpc.GetVersionArgs{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &trpc.GetVersionResult{}
+	if err := decodeResult("script.GetVersion", jsonBytes, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ScriptClient) GetAccount(ctx context.Context, address string, height tcommon.JSONUint64, preview bool) (*types.Account, error) {
+	jsonBytes, err := c.call(ctx, "script.GetAccount", trpc.GetAccountArgs{Address: address, Height: height, Preview: preview})
+	if err != nil {
+		return nil, err
+	}
+
+	result := trpc.GetAccountResult{Account: &types.Account{}}
+	if err := decodeResult("script.GetAccount", jsonBytes, &result); err != nil {
+		return nil, err
+	}
+	if result.Account == nil {
+		result.Account = &types.Account{}
+	}
+	return result.Account, nil
+}
+
+func (c *ScriptClient) GetCode(ctx context.Context, address string, height tcommon.JSONUint64) (string, error) {
+	jsonBytes, err := c.call(ctx, "script.GetCode", trpc.GetCodeArgs{Address: address, Height: height})
+	if err != nil {
+		return "", err
+	}
+
+	result := trpc.GetCodeResult{}
+	if err := decodeResult("script.GetCode", jsonBytes, &result); err != nil {
+		return "", err
+	}
+	return result.Code, nil
+}
+
+func (c *ScriptClient) GetStorageAt(ctx context.Context, address string, storagePosition string, height tcommon.JSONUint64) (string, error) {
+	jsonBytes, err := c.call(ctx, "script.GetStorageAt", trpc.GetStorageAtArgs{
+		Address:         address,
+		StoragePosition: storagePosition,
+		Height:          height})
+	if err != nil {
+		return "", err
+	}
+
+	result := trpc.GetStorageAtResult{}
+	if err := decodeResult("script.GetStorageAt", jsonBytes, &result); err != nil {
+		return "", err
+	}
+	return result.Value, nil
+}
+
+func (c *ScriptClient) GetBlock(ctx context.Context, hash tcommon.Hash) (*common.ScriptGetBlockResultInner, error) {
+	jsonBytes, err := c.call(ctx, "script.GetBlock", trpc.GetBlockArgs{Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+	return decodeBlock("script.GetBlock", jsonBytes)
+}
+
+func (c *ScriptClient) GetBlockByHeight(ctx context.Context, height tcommon.JSONUint64) (*common.ScriptGetBlockResultInner, error) {
+	jsonBytes, err := c.call(ctx, "script.GetBlockByHeight", trpc.GetBlockByHeightArgs{Height: height})
+	if err != nil {
+		return nil, err
+	}
+	return decodeBlock("script.GetBlockByHeight", jsonBytes)
+}
+
+func (c *ScriptClient) GetBlocksByRange(ctx context.Context, start tcommon.JSONUint64, end tcommon.JSONUint64) (common.ScriptGetBlocksResult, error) {
+	jsonBytes, err := c.call(ctx, "script.GetBlocksByRange", trpc.GetBlocksByRangeArgs{Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+
+	result := common.ScriptGetBlocksResult{}
+	var objList []json.RawMessage
+	if err := decodeResult("script.GetBlocksByRange", jsonBytes, &objList); err != nil {
+		return nil, err
+	}
+	for _, blockJsonBytes := range objList {
+		block, err := decodeBlock("script.GetBlocksByRange", blockJsonBytes)
+		if err == ErrEmptyBlock {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, block)
+	}
+	return result, nil
+}
+
+func (c *ScriptClient) GetTransaction(ctx context.Context, hash string) (*trpc.GetTransactionResult, error) {
+	jsonBytes, err := c.call(ctx, "script.GetTransaction", trpc.GetTransactionArgs{Hash: hash})
+	if err != nil {
+		return nil, err
+	}
+	return decodeTransaction("script.GetTransaction", jsonBytes)
+}
+
+func (c *ScriptClient) CallSmartContract(ctx context.Context, sctxBytes []byte) (*trpc.CallSmartContractResult, error) {
+	jsonBytes, err := c.call(ctx, "script.CallSmartContract", trpc.CallSmartContractArgs{SctxBytes: hex.EncodeToString(sctxBytes)})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &trpc.CallSmartContractResult{}
+	if err := decodeResult("script.CallSmartContract", jsonBytes, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ScriptClient) BroadcastRawTransactionAsync(ctx context.Context, txBytes string) (string, error) {
+	return c.broadcast(ctx, "script.BroadcastRawTransactionAsync", txBytes)
+}
+
+func (c *ScriptClient) BroadcastRawEthTransactionAsync(ctx context.Context, txBytes string) (string, error) {
+	return c.broadcast(ctx, "script.BroadcastRawEthTransactionAsync", txBytes)
+}
+
+func (c *ScriptClient) RawCall(ctx context.Context, method string, args interface{}) (json.RawMessage, error) {
+	return c.call(ctx, method, args)
+}
+
+func (c *ScriptClient) broadcast(ctx context.Context, method string, txBytes string) (string, error) {
+	jsonBytes, err := c.call(ctx, method, trpc.BroadcastRawTransactionAsyncArgs{TxBytes: txBytes})
+	if err != nil {
+		return "", err
+	}
+
+	result := trpc.BroadcastRawTransactionAsyncResult{}
+	if err := decodeResult(method, jsonBytes, &result); err != nil {
+		return "", err
+	}
+	return result.TxHash, nil
+}
@@ -0,0 +1,37 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  string
+		wantErr bool
+	}{
+		{"valid", `{"chain_id":"scriptnet","latest_finalized_block_height":"42"}`, false},
+		{"malformed", `["not","a","status"]`, true},
+		{"wrong field type", `{"latest_finalized_block_height":{}}`, true},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + test.result + `}`))
+		}))
+
+		status, err := NewScriptClient(server.URL).GetStatus(context.Background())
+		server.Close()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: GetStatus() error = %v, wantErr %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err == nil && (status.ChainID != "scriptnet" || status.LatestFinalizedBlockHeight != 42) {
+			t.Errorf("%v: GetStatus() = %+v", test.name, status)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+	trpc "github.com/scripttoken/script/rpc"
+)
+
+// decodeResult decodes the result of the given Script RPC method into v
+func decodeResult(method string, jsonBytes []byte, v interface{}) error {
+	if err := json.Unmarshal(jsonBytes, v); err != nil {
+		return fmt.Errorf("decode %s: %w", method, err)
+	}
+	return nil
+}
+
+// decodeBlock decodes a block returned by the Script node. The raw transactions cannot be
+// unmarshalled into the types.Tx interface directly, so the smart contract and send
+// transactions are decoded into their concrete types based on the tx type.
+func decodeBlock(method string, jsonBytes []byte) (*common.ScriptGetBlockResultInner, error) {
+	block := common.ScriptGetBlockResult{}
+	if err := decodeResult(method, jsonBytes, &block); err != nil {
+		return nil, err
+	}
+	if block.ScriptGetBlockResultInner == nil {
+		return nil, ErrEmptyBlock
+	}
+
+	var objmap map[string]json.RawMessage
+	if err := decodeResult(method, jsonBytes, &objmap); err != nil {
+		return nil, err
+	}
+	if objmap["transactions"] != nil {
+		var txmaps []map[string]json.RawMessage
+		if err := decodeResult(method, objmap["transactions"], &txmaps); err != nil {
+			return nil, err
+		}
+		for i, omap := range txmaps {
+			if i >= len(block.Txs) {
+				break
+			}
+			tx, err := decodeTx(method, types.TxType(block.Txs[i].Type), omap["raw"])
+			if err != nil {
+				return nil, err
+			}
+			block.Txs[i].Tx = tx
+		}
+	}
+
+	return block.ScriptGetBlockResultInner, nil
+}
+
+// decodeTransaction decodes the result of script.GetTransaction. The transaction body is
+// only decoded once the transaction is included in a block.
+func decodeTransaction(method string, jsonBytes []byte) (*trpc.GetTransactionResult, error) {
+	result := &trpc.GetTransactionResult{}
+	if err := decodeResult(method, jsonBytes, result); err != nil {
+		return nil, err
+	}
+	if (result.BlockHash == tcommon.Hash{}) {
+		return result, nil // The tx is not finalized yet
+	}
+
+	var objmap map[string]json.RawMessage
+	if err := decodeResult(method, jsonBytes, &objmap); err != nil {
+		return nil, err
+	}
+	if objmap["transaction"] != nil {
+		tx, err := decodeTx(method, types.TxType(result.Type), objmap["transaction"])
+		if err != nil {
+			return nil, err
+		}
+		result.Tx = tx
+	}
+	return result, nil
+}
+
+func decodeTx(method string, txType types.TxType, raw json.RawMessage) (types.Tx, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	switch txType {
+	case types.TxSmartContract:
+		tx := types.SmartContractTx{}
+		if err := decodeResult(method, raw, &tx); err != nil {
+			return nil, err
+		}
+		return &tx, nil
+	case types.TxSend:
+		tx := types.SendTx{}
+		if err := decodeResult(method, raw, &tx); err != nil {
+			return nil, err
+		}
+		return &tx, nil
+	}
+	//TODO: handle other types
+	return nil, nil
+}
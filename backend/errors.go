@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	rpcc "github.com/ybbus/jsonrpc"
+)
+
+// ErrNotFinalized is returned when the queried transaction is not finalized yet
+var ErrNotFinalized = errors.New("transaction not finalized yet")
+
+// TransportError is returned when the Script node could not be reached, did not answer in
+// time, or sent back a response that could not be read
+type TransportError struct {
+	Method string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("failed to get script RPC response: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// RPCError is returned when the Script node answers a call with an error object
+type RPCError struct {
+	Method string
+	Err    *rpcc.RPCError
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("script RPC returns an error: %v", e.Err)
+}
+
+// IsRetriable tells whether a failed upstream query may succeed if issued again. Transport
+// failures are transient, and so are blocks and transactions which are not finalized yet.
+// Errors reported by the node itself, and the cancellation of the caller, are final.
+func IsRetriable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if err == ErrEmptyBlock || err == ErrNotFinalized {
+		return true
+	}
+
+	var transportErr *TransportError
+	return errors.As(err, &transportErr)
+}
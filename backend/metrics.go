@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "adaptor_upstream_request_duration_seconds",
+		Help:    "Latency of the calls to the Script node, by script.* method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "adaptor_upstream_errors_total",
+		Help: "Failed calls to the Script node, by script.* method and kind: transport, rpc or canceled.",
+	}, []string{"method", "kind"})
+)
+
+// observeUpstreamCall records the latency and the outcome of a call to the Script node
+func observeUpstreamCall(method string, start time.Time, err error) {
+	upstreamDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return
+	}
+
+	var rpcErr *RPCError
+	switch {
+	case errors.Is(err, context.Canceled):
+		upstreamErrors.WithLabelValues(method, "canceled").Inc()
+	case errors.As(err, &rpcErr):
+		upstreamErrors.WithLabelValues(method, "rpc").Inc()
+	default:
+		upstreamErrors.WithLabelValues(method, "transport").Inc()
+	}
+}
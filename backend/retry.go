@@ -0,0 +1,68 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/tracing"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RetryPolicy describes how upstream queries are retried, e.g. while waiting for a block or
+// a transaction to be finalized
+type RetryPolicy struct {
+	Attempts   int           // total number of attempts, including the first one
+	Backoff    time.Duration // wait before the first retry
+	MaxBackoff time.Duration // upper bound of the wait, which doubles after every retry
+}
+
+// RetryPolicyFromConfig returns the retry policy set in the config
+func RetryPolicyFromConfig() RetryPolicy {
+	return RetryPolicy{
+		Attempts:   viper.GetInt(common.CfgScriptRetryAttempts),
+		Backoff:    time.Duration(viper.GetInt64(common.CfgScriptRetryBackoffMillis)) * time.Millisecond,
+		MaxBackoff: time.Duration(viper.GetInt64(common.CfgScriptRetryMaxBackoffMillis)) * time.Millisecond,
+	}
+}
+
+// Do calls fn until it succeeds, fails with an error which is not retriable, or the attempts
+// are exhausted, and returns the last error. The waits between the attempts are interrupted
+// as soon as ctx is done. Each retry is traced as a span covering the wait and the attempt.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) (err error) {
+	backoff := p.Backoff
+	var span trace.Span // of the current retry, nil for the first attempt
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if span != nil {
+			tracing.SetError(span, err)
+			span.End()
+		}
+		if err == nil || !IsRetriable(err) || attempt >= p.Attempts {
+			return err
+		}
+
+		logger.Debugf("Retrying upstream query, attempt: %v, backoff: %v, err: %v", attempt, backoff, err)
+		_, span = tracing.StartSpan(ctx, "retry", trace.SpanKindInternal,
+			attribute.Int("retry.attempt", attempt+1),
+			attribute.Int64("retry.backoff_ms", backoff.Milliseconds()),
+			attribute.String("retry.cause", err.Error()))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			tracing.SetError(span, ctx.Err())
+			span.End()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
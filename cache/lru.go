@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of the counters of a cache
+type Stats struct {
+	Name      string
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+	Bytes     int64
+	MaxBytes  int64
+}
+
+type entry struct {
+	key   interface{}
+	value interface{}
+	size  int64
+}
+
+// LRU is a least-recently-used cache bounded by the total size of its values in bytes,
+// rather than by their number, since a block with many transactions can be orders of
+// magnitude larger than an empty one. It is safe for concurrent use. The cached values are
+// shared between the callers, and must not be modified.
+type LRU struct {
+	hits      uint64 // accessed atomically, keep it 64-bit aligned
+	misses    uint64 // accessed atomically
+	evictions uint64 // accessed atomically
+
+	name     string
+	maxBytes int64
+
+	mu      sync.Mutex
+	bytes   int64
+	items   map[interface{}]*list.Element
+	recency *list.List // front is the most recently used
+}
+
+var (
+	registryLock sync.Mutex
+	registry     []*LRU
+)
+
+// NewLRU creates a cache holding at most maxBytes of values. The cache is registered under
+// the given name so that its counters are reported by AllStats. A non-positive maxBytes
+// disables the cache.
+func NewLRU(name string, maxBytes int64) *LRU {
+	c := &LRU{
+		name:     name,
+		maxBytes: maxBytes,
+		items:    make(map[interface{}]*list.Element),
+		recency:  list.New(),
+	}
+
+	registryLock.Lock()
+	registry = append(registry, c)
+	registryLock.Unlock()
+
+	return c
+}
+
+// Get returns the value cached under the key, and marks it as recently used
+func (c *LRU) Get(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	elem, ok := c.items[key]
+	if ok {
+		c.recency.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return elem.Value.(*entry).value, true
+}
+
+// Add caches the value under the key, evicting the least recently used values until the
+// cache fits in its size bound. Values larger than the bound are not cached.
+func (c *LRU) Add(key interface{}, value interface{}, size int64) {
+	if c.maxBytes <= 0 || size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+
+	c.items[key] = c.recency.PushFront(&entry{key: key, value: value, size: size})
+	c.bytes += size
+
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.recency.Back())
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+func (c *LRU) removeElement(elem *list.Element) {
+	e := c.recency.Remove(elem).(*entry)
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
+
+// Stats returns a snapshot of the counters of the cache
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	entries, bytes := len(c.items), c.bytes
+	c.mu.Unlock()
+
+	return Stats{
+		Name:      c.name,
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Entries:   entries,
+		Bytes:     bytes,
+		MaxBytes:  c.maxBytes,
+	}
+}
+
+// AllStats returns the counters of all the caches created so far
+func AllStats() []Stats {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	stats := make([]Stats, 0, len(registry))
+	for _, c := range registry {
+		stats = append(stats, c.Stats())
+	}
+	return stats
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheHits = prometheus.NewDesc("adaptor_cache_hits_total",
+		"Cache hits, by cache.", []string{"cache"}, nil)
+	cacheMisses = prometheus.NewDesc("adaptor_cache_misses_total",
+		"Cache misses, by cache.", []string{"cache"}, nil)
+	cacheEvictions = prometheus.NewDesc("adaptor_cache_evictions_total",
+		"Cache evictions, by cache.", []string{"cache"}, nil)
+	cacheEntries = prometheus.NewDesc("adaptor_cache_entries",
+		"Cached entries, by cache.", []string{"cache"}, nil)
+	cacheBytes = prometheus.NewDesc("adaptor_cache_bytes",
+		"Size of the cached values in bytes, by cache.", []string{"cache"}, nil)
+)
+
+// statsCollector reads the counters of all the caches each time the metrics are scraped
+type statsCollector struct{}
+
+func (statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHits
+	ch <- cacheMisses
+	ch <- cacheEvictions
+	ch <- cacheEntries
+	ch <- cacheBytes
+}
+
+func (statsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range AllStats() {
+		ch <- prometheus.MustNewConstMetric(cacheHits, prometheus.CounterValue, float64(stats.Hits), stats.Name)
+		ch <- prometheus.MustNewConstMetric(cacheMisses, prometheus.CounterValue, float64(stats.Misses), stats.Name)
+		ch <- prometheus.MustNewConstMetric(cacheEvictions, prometheus.CounterValue, float64(stats.Evictions), stats.Name)
+		ch <- prometheus.MustNewConstMetric(cacheEntries, prometheus.GaugeValue, float64(stats.Entries), stats.Name)
+		ch <- prometheus.MustNewConstMetric(cacheBytes, prometheus.GaugeValue, float64(stats.Bytes), stats.Name)
+	}
+}
+
+func init() {
+	prometheus.MustRegister(statsCollector{})
+}
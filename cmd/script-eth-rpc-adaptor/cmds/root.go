@@ -52,6 +52,7 @@ func init() {
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	viper.AddConfigPath(cfgPath)
+	viper.Set(common.CfgConfigPath, cfgPath)
 	// Search config (without extension).
 	viper.SetConfigName("config")
 
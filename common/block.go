@@ -0,0 +1,157 @@
+package common
+
+import (
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+)
+
+// rlpList is a list of RLP encoded items whose root can be derived the Ethereum way,
+// i.e. as the root of a Merkle Patricia trie keyed by the RLP encoded item indices
+type rlpList [][]byte
+
+func (l rlpList) Len() int            { return len(l) }
+func (l rlpList) GetRlp(i int) []byte { return l[i] }
+
+// ethLegacyTx is the RLP layout of an Ethereum legacy transaction
+type ethLegacyTx struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	Gas      uint64
+	To       *gethcommon.Address `rlp:"nil"`
+	Value    *big.Int
+	Data     []byte
+	V, R, S  *big.Int
+}
+
+// EthTxRlp encodes the Ethereum view of a smart contract transaction, i.e. the nonce, gas
+// price, gas, recipient, value, input and signature returned by eth_getTransactionByHash, as
+// an Ethereum legacy transaction
+func EthTxRlp(tx *types.SmartContractTx) ([]byte, error) {
+	ethTx := ethLegacyTx{
+		GasPrice: new(big.Int),
+		Gas:      tx.GasLimit,
+		Value:    new(big.Int),
+		Data:     tx.Data,
+		V:        new(big.Int),
+		R:        new(big.Int),
+		S:        new(big.Int),
+	}
+	if tx.From.Sequence > 0 {
+		ethTx.Nonce = tx.From.Sequence - 1 // Ethereum's account nonce starts from 0, while Script's account sequence starts from 1
+	}
+	if tx.GasPrice != nil {
+		ethTx.GasPrice = tx.GasPrice
+	}
+	if (tx.To.Address != tcommon.Address{}) {
+		to := gethcommon.Address(tx.To.Address)
+		ethTx.To = &to
+	}
+	if tx.From.Coins.SPAYWei != nil {
+		ethTx.Value = tx.From.Coins.SPAYWei
+	}
+	if tx.From.Signature != nil {
+		if sig := tx.From.Signature.ToBytes(); len(sig) >= 65 {
+			ethTx.R.SetBytes(sig[0:32])
+			ethTx.S.SetBytes(sig[32:64])
+			ethTx.V.SetUint64(uint64(sig[64]))
+		}
+	}
+	return rlp.EncodeToBytes(&ethTx)
+}
+
+// EthBlockData holds what the Ethereum view of a block derives from its smart contract
+// transactions and their receipts
+type EthBlockData struct {
+	GasUsed      uint64
+	TxsRoot      gethcommon.Hash
+	ReceiptsRoot gethcommon.Hash
+	TxsRlp       [][]byte
+}
+
+// DeriveEthBlockData encodes the smart contract transactions of the block and their
+// receipts, as listed in the Ethereum view of the block. The transactions are encoded as
+// Ethereum legacy transactions, see EthTxRlp, so that the clients can verify the root of the
+// transactions they receive.
+func DeriveEthBlockData(block *ScriptGetBlockResultInner) EthBlockData {
+	data := EthBlockData{}
+	receiptsRlp := rlpList{}
+	for _, tx := range block.Txs {
+		if types.TxType(tx.Type) != types.TxSmartContract || tx.Tx == nil {
+			continue
+		}
+
+		txBytes, err := EthTxRlp(tx.Tx.(*types.SmartContractTx))
+		if err != nil {
+			logger.Warnf("Failed to encode tx %v: %v", tx.Hash.Hex(), err)
+			continue
+		}
+		data.TxsRlp = append(data.TxsRlp, txBytes)
+
+		receipt := &gethtypes.Receipt{Status: gethtypes.ReceiptStatusSuccessful}
+		if tx.Receipt != nil {
+			data.GasUsed += tx.Receipt.GasUsed
+			if tx.Receipt.EvmErr != "" {
+				receipt.Status = gethtypes.ReceiptStatusFailed
+			}
+			for _, log := range tx.Receipt.Logs {
+				ethLog := &gethtypes.Log{Address: gethcommon.Address(log.Address), Data: log.Data}
+				for _, topic := range log.Topics {
+					ethLog.Topics = append(ethLog.Topics, gethcommon.Hash(topic))
+				}
+				receipt.Logs = append(receipt.Logs, ethLog)
+			}
+			bloom := LogsBloom(tx.Receipt.Logs)
+			receipt.Bloom = gethtypes.BytesToBloom(bloom[:])
+		}
+		receipt.CumulativeGasUsed = data.GasUsed
+
+		receiptBytes, err := rlp.EncodeToBytes(receipt)
+		if err != nil {
+			logger.Warnf("Failed to encode the receipt of tx %v: %v", tx.Hash.Hex(), err)
+			continue
+		}
+		receiptsRlp = append(receiptsRlp, receiptBytes)
+	}
+
+	data.TxsRoot = gethtypes.DeriveSha(rlpList(data.TxsRlp))
+	data.ReceiptsRoot = gethtypes.DeriveSha(receiptsRlp)
+	return data
+}
+
+// EthBlockSize returns the size of the RLP encoding of the block, i.e. of its header, its
+// transactions and its (empty) uncles, as Ethereum nodes report it
+func EthBlockSize(header *gethtypes.Header, txsRlp [][]byte) uint64 {
+	txs := make([]rlp.RawValue, len(txsRlp))
+	for i, txBytes := range txsRlp {
+		txs[i] = txBytes
+	}
+	blockBytes, err := rlp.EncodeToBytes([]interface{}{header, txs, []*gethtypes.Header{}})
+	if err != nil {
+		return 0
+	}
+	return uint64(len(blockBytes))
+}
+
+// EthHeader builds the Ethereum header of the block, it is used to compute the block size
+func EthHeader(block *ScriptGetBlockResultInner, data EthBlockData, bloom Bloom, gasLimit uint64) *gethtypes.Header {
+	return &gethtypes.Header{
+		ParentHash:  gethcommon.Hash(block.Parent),
+		UncleHash:   gethtypes.EmptyUncleHash,
+		Coinbase:    gethcommon.Address(block.Proposer),
+		Root:        gethcommon.Hash(block.StateHash),
+		TxHash:      data.TxsRoot,
+		ReceiptHash: data.ReceiptsRoot,
+		Bloom:       gethtypes.BytesToBloom(bloom[:]),
+		Difficulty:  big.NewInt(0),
+		Number:      new(big.Int).SetUint64(uint64(block.Height)),
+		GasLimit:    gasLimit,
+		GasUsed:     data.GasUsed,
+		Time:        block.Timestamp.ToInt().Uint64(),
+		Extra:       []byte{},
+	}
+}
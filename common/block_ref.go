@@ -0,0 +1,89 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/common/hexutil"
+)
+
+// BlockRef is the block parameter of eth_call, eth_estimateGas and the state methods: a hex
+// block number, a tag ("latest", "pending", "safe", "finalized" or "earliest"), or an EIP-1898
+// object with either a blockNumber or a blockHash and requireCanonical. Bare JSON numbers and
+// other malformed input fail to unmarshal, which the RPC server reports as an invalid params error.
+type BlockRef struct {
+	Tag              string
+	Number           *tcommon.JSONUint64
+	Hash             *tcommon.Hash
+	RequireCanonical bool
+}
+
+type blockRefObject struct {
+	BlockNumber      *string `json:"blockNumber"`
+	BlockHash        *string `json:"blockHash"`
+	RequireCanonical bool    `json:"requireCanonical"`
+}
+
+// UnmarshalJSON accepts a hex string, a tag or an EIP-1898 object
+func (r *BlockRef) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		return r.parseString(str)
+	}
+
+	obj := blockRefObject{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&obj); err != nil {
+		return fmt.Errorf("invalid block parameter: %s", string(data))
+	}
+	if (obj.BlockNumber == nil) == (obj.BlockHash == nil) {
+		return fmt.Errorf("exactly one of blockNumber and blockHash must be specified")
+	}
+	if obj.BlockHash != nil {
+		hashBytes, err := hexutil.Decode(*obj.BlockHash)
+		if err != nil || len(hashBytes) != tcommon.HashLength {
+			return fmt.Errorf("invalid block hash: %v", *obj.BlockHash)
+		}
+		hash := tcommon.BytesToHash(hashBytes)
+		r.Hash = &hash
+		r.RequireCanonical = obj.RequireCanonical
+		return nil
+	}
+	return r.parseString(*obj.BlockNumber)
+}
+
+func (r *BlockRef) parseString(str string) error {
+	switch str {
+	case "latest", "pending", "safe", "finalized", "earliest":
+		r.Tag = str
+		return nil
+	}
+	if !strings.HasPrefix(str, "0x") || len(str) < 3 {
+		return fmt.Errorf("invalid block number: %v", str)
+	}
+	number, err := strconv.ParseUint(str[2:], 16, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number: %v", str)
+	}
+	height := tcommon.JSONUint64(number)
+	r.Number = &height
+	return nil
+}
+
+// String returns the block parameter as the client passed it
+func (r *BlockRef) String() string {
+	switch {
+	case r == nil:
+		return "latest"
+	case r.Hash != nil:
+		return r.Hash.Hex()
+	case r.Number != nil:
+		return fmt.Sprintf("%v", uint64(*r.Number))
+	}
+	return r.Tag
+}
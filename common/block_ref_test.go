@@ -0,0 +1,68 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBlockRefUnmarshalJSON(t *testing.T) {
+	hash := "0xab0000000000000000000000000000000000000000000000000000000000cdef"
+
+	tests := []struct {
+		name             string
+		input            string
+		wantErr          bool
+		wantTag          string
+		wantNumber       uint64
+		wantHash         string
+		requireCanonical bool
+	}{
+		{name: "hex number", input: `"0x1b4"`, wantNumber: 0x1b4},
+		{name: "zero", input: `"0x0"`, wantNumber: 0},
+		{name: "latest", input: `"latest"`, wantTag: "latest"},
+		{name: "pending", input: `"pending"`, wantTag: "pending"},
+		{name: "safe", input: `"safe"`, wantTag: "safe"},
+		{name: "finalized", input: `"finalized"`, wantTag: "finalized"},
+		{name: "earliest", input: `"earliest"`, wantTag: "earliest"},
+		{name: "object with number", input: `{"blockNumber":"0x10"}`, wantNumber: 0x10},
+		{name: "object with tag", input: `{"blockNumber":"latest"}`, wantTag: "latest"},
+		{name: "object with hash", input: `{"blockHash":"` + hash + `"}`, wantHash: hash},
+		{name: "object with canonical hash", input: `{"blockHash":"` + hash + `","requireCanonical":true}`, wantHash: hash, requireCanonical: true},
+		{name: "bare number", input: `436`, wantErr: true},
+		{name: "bare float", input: `1.5`, wantErr: true},
+		{name: "decimal string", input: `"436"`, wantErr: true},
+		{name: "empty hex", input: `"0x"`, wantErr: true},
+		{name: "invalid hex", input: `"0xzz"`, wantErr: true},
+		{name: "overflow", input: `"0x10000000000000000"`, wantErr: true},
+		{name: "unknown tag", input: `"newest"`, wantErr: true},
+		{name: "null", input: `null`, wantErr: true},
+		{name: "empty object", input: `{}`, wantErr: true},
+		{name: "number and hash", input: `{"blockNumber":"0x10","blockHash":"` + hash + `"}`, wantErr: true},
+		{name: "short hash", input: `{"blockHash":"0xabcd"}`, wantErr: true},
+		{name: "unknown field", input: `{"blockNumber":"0x10","foo":1}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		ref := BlockRef{}
+		err := json.Unmarshal([]byte(test.input), &ref)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: Unmarshal(%v) error = %v, wantErr %v", test.name, test.input, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if ref.Tag != test.wantTag {
+			t.Errorf("%v: Tag = %q, want %q", test.name, ref.Tag, test.wantTag)
+		}
+		if test.wantTag == "" && test.wantHash == "" && (ref.Number == nil || uint64(*ref.Number) != test.wantNumber) {
+			t.Errorf("%v: Number = %v, want %v", test.name, ref.Number, test.wantNumber)
+		}
+		if test.wantHash != "" && (ref.Hash == nil || ref.Hash.Hex() != test.wantHash) {
+			t.Errorf("%v: Hash = %v, want %v", test.name, ref.Hash, test.wantHash)
+		}
+		if ref.RequireCanonical != test.requireCanonical {
+			t.Errorf("%v: RequireCanonical = %v, want %v", test.name, ref.RequireCanonical, test.requireCanonical)
+		}
+	}
+}
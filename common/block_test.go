@@ -0,0 +1,96 @@
+package common
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/crypto"
+	"github.com/scripttoken/script/ledger/types"
+)
+
+func TestEthTxRlp(t *testing.T) {
+	sigBytes := make([]byte, 65)
+	sigBytes[31], sigBytes[63], sigBytes[64] = 1, 2, 28
+	sig, err := crypto.SignatureFromBytes(sigBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := tcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	ethTo := gethcommon.Address(to)
+
+	tests := []struct {
+		name    string
+		tx      *types.SmartContractTx
+		nonce   uint64
+		to      *gethcommon.Address
+		value   *big.Int
+		v, r, s *big.Int
+	}{
+		{
+			name: "call",
+			tx: &types.SmartContractTx{
+				From:     types.TxInput{Coins: types.Coins{SPAYWei: big.NewInt(7)}, Sequence: 3, Signature: sig},
+				To:       types.TxOutput{Address: to},
+				GasLimit: 21000,
+				GasPrice: big.NewInt(4000),
+				Data:     []byte{0xca, 0xfe},
+			},
+			nonce: 2,
+			to:    &ethTo,
+			value: big.NewInt(7),
+			v:     big.NewInt(28),
+			r:     big.NewInt(1),
+			s:     big.NewInt(2),
+		},
+		{
+			name: "contract creation without signature",
+			tx: &types.SmartContractTx{
+				From:     types.TxInput{Sequence: 1},
+				GasLimit: 100000,
+				Data:     []byte{0x60, 0x80},
+			},
+			nonce: 0,
+			value: new(big.Int),
+			v:     new(big.Int),
+			r:     new(big.Int),
+			s:     new(big.Int),
+		},
+	}
+
+	for _, test := range tests {
+		txBytes, err := EthTxRlp(test.tx)
+		if err != nil {
+			t.Errorf("%v: EthTxRlp() error = %v", test.name, err)
+			continue
+		}
+		var ethTx gethtypes.Transaction
+		if err := rlp.DecodeBytes(txBytes, &ethTx); err != nil {
+			t.Errorf("%v: not an Ethereum transaction: %v", test.name, err)
+			continue
+		}
+		if ethTx.Nonce() != test.nonce {
+			t.Errorf("%v: nonce = %v, want %v", test.name, ethTx.Nonce(), test.nonce)
+		}
+		if ethTx.Gas() != test.tx.GasLimit {
+			t.Errorf("%v: gas = %v, want %v", test.name, ethTx.Gas(), test.tx.GasLimit)
+		}
+		if (ethTx.To() == nil) != (test.to == nil) || (test.to != nil && *ethTx.To() != *test.to) {
+			t.Errorf("%v: to = %v, want %v", test.name, ethTx.To(), test.to)
+		}
+		if ethTx.Value().Cmp(test.value) != 0 {
+			t.Errorf("%v: value = %v, want %v", test.name, ethTx.Value(), test.value)
+		}
+		if !bytes.Equal(ethTx.Data(), test.tx.Data) {
+			t.Errorf("%v: data = %x, want %x", test.name, ethTx.Data(), test.tx.Data)
+		}
+		v, r, s := ethTx.RawSignatureValues()
+		if v.Cmp(test.v) != 0 || r.Cmp(test.r) != 0 || s.Cmp(test.s) != 0 {
+			t.Errorf("%v: signature = (%v, %v, %v), want (%v, %v, %v)", test.name, v, r, s, test.v, test.r, test.s)
+		}
+	}
+}
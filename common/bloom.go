@@ -0,0 +1,67 @@
+package common
+
+import (
+	"encoding/hex"
+
+	"github.com/scripttoken/script/crypto"
+	"github.com/scripttoken/script/ledger/types"
+)
+
+// BloomByteLength is the length of an Ethereum logs bloom, i.e. 2048 bits
+const BloomByteLength = 256
+
+// Bloom is the Ethereum logs bloom filter, see the yellow paper, section 4.3.1. Each log sets
+// the bits picked by the Keccak-256 hash of its address and of each of its topics.
+type Bloom [BloomByteLength]byte
+
+// Add sets the 3 bits picked by the hash of the given data
+func (b *Bloom) Add(data []byte) {
+	hash := crypto.Keccak256(data)
+	for i := 0; i < 6; i += 2 {
+		bit := (uint(hash[i])<<8 | uint(hash[i+1])) & (BloomByteLength*8 - 1)
+		b[BloomByteLength-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// AddLog sets the bits of the address and the topics of the log
+func (b *Bloom) AddLog(log *types.Log) {
+	b.Add(log.Address.Bytes())
+	for _, topic := range log.Topics {
+		b.Add(topic.Bytes())
+	}
+}
+
+// Or merges the bits of the other bloom into b
+func (b *Bloom) Or(other *Bloom) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
+
+// Hex returns the 0x-prefixed hex encoding of the bloom
+func (b Bloom) Hex() string {
+	return "0x" + hex.EncodeToString(b[:])
+}
+
+// LogsBloom returns the bloom of the given logs, as in a transaction receipt
+func LogsBloom(logs []*types.Log) Bloom {
+	bloom := Bloom{}
+	for _, log := range logs {
+		bloom.AddLog(log)
+	}
+	return bloom
+}
+
+// BlockBloom returns the union of the blooms of the receipts of the block
+func BlockBloom(block *ScriptGetBlockResultInner) Bloom {
+	bloom := Bloom{}
+	for _, tx := range block.Txs {
+		if tx.Receipt == nil {
+			continue
+		}
+		for _, log := range tx.Receipt.Logs {
+			bloom.AddLog(log)
+		}
+	}
+	return bloom
+}
@@ -14,6 +14,24 @@ const (
 	// CfgScriptRPCEndpoint configures the Script RPC endpoint
 	CfgScriptRPCEndpoint   = "script.rpcEndpoint"
 	CfgScriptBlockGasLimit = "script.blockGasLimit"
+	// CfgScriptRPCTimeoutSecs sets the timeout of a single call to the Script RPC endpoint
+	CfgScriptRPCTimeoutSecs = "script.rpcTimeoutSecs"
+	// CfgScriptRPCMethodTimeoutSecs overrides the call timeout for individual Script RPC methods,
+	// e.g. script.rpcMethodTimeoutSecs.GetBlocksByRange
+	CfgScriptRPCMethodTimeoutSecs = "script.rpcMethodTimeoutSecs"
+	// CfgScriptRPCMaxIdleConns sets the number of idle connections kept alive to the Script RPC endpoint
+	CfgScriptRPCMaxIdleConns = "script.rpcMaxIdleConns"
+	// CfgScriptRetryAttempts sets how many times a query is attempted while waiting for a block or tx to be finalized
+	CfgScriptRetryAttempts = "script.retryAttempts"
+	// CfgScriptRetryBackoffMillis sets the wait before the first retry, it doubles after every retry
+	CfgScriptRetryBackoffMillis = "script.retryBackoffMillis"
+	// CfgScriptRetryMaxBackoffMillis caps the wait between two retries
+	CfgScriptRetryMaxBackoffMillis = "script.retryMaxBackoffMillis"
+
+	// CfgScriptExtendedCallMethod sets the Script RPC method the eth_call and eth_estimateGas calls at a past height
+	// are forwarded to, for nodes which keep and can execute against historical state. When empty, the calls at a
+	// past height fail, only the latest state is available through script.CallSmartContract.
+	CfgScriptExtendedCallMethod = "script.extendedCallMethod"
 
 	// CfgRPCEnabled sets whether to run RPC service.
 	CfgRPCEnabled = "rpc.enabled"
@@ -25,13 +43,157 @@ const (
 	CfgRPCWSAddress = "rpc.wsAddress"
 	// CfgRPCWSPort sets the port of RPC websocket service.
 	CfgRPCWSPort = "rpc.wsPort"
+	// CfgRPCHttpModules lists the namespaces exposed through the RPC http service
+	CfgRPCHttpModules = "rpc.httpModules"
+	// CfgRPCWSModules lists the namespaces exposed through the RPC websocket service
+	CfgRPCWSModules = "rpc.wsModules"
+	// CfgRPCCorsOrigins lists the origins allowed to call the RPC http service from a browser, "*" allows any
+	CfgRPCCorsOrigins = "rpc.corsOrigins"
+	// CfgRPCWSOrigins lists the origins allowed to open a websocket connection, "*" allows any
+	CfgRPCWSOrigins = "rpc.wsOrigins"
+	// CfgRPCVirtualHosts lists the Host header values accepted by the RPC http service, "*" accepts any
+	CfgRPCVirtualHosts = "rpc.vhosts"
+	// CfgRPCPrivateMethods lists the methods which are not served by the public http & websocket services,
+	// so that they are only reachable through the authenticated RPC service
+	CfgRPCPrivateMethods = "rpc.privateMethods"
 	// CfgRPCMaxConnections limits concurrent connections accepted by RPC server.
 	CfgRPCMaxConnections = "rpc.maxConnections"
-	// CfgRPCTimeoutSecs set a timeout for RPC.
+	// CfgRPCTimeoutSecs sets the time allowed to serve an HTTP request, the calls still running after it are canceled.
 	CfgRPCTimeoutSecs = "rpc.timeoutSecs"
+	// CfgRPCReadTimeoutSecs sets the time allowed to read an HTTP request, headers and body included
+	CfgRPCReadTimeoutSecs = "rpc.readTimeoutSecs"
+	// CfgRPCIdleTimeoutSecs sets how long an idle keep-alive HTTP connection is kept open
+	CfgRPCIdleTimeoutSecs = "rpc.idleTimeoutSecs"
+	// CfgRPCWSPingIntervalSecs sets how often the WS connections are pinged, the ones not answering within
+	// another interval are closed
+	CfgRPCWSPingIntervalSecs = "rpc.wsPingIntervalSecs"
+	// CfgRPCShutdownTimeoutSecs sets how long the in-flight requests are waited for when the servers stop
+	CfgRPCShutdownTimeoutSecs = "rpc.shutdownTimeoutSecs"
+	// CfgRPCBatchRequestLimit caps the number of requests in a JSON-RPC batch
+	CfgRPCBatchRequestLimit = "rpc.batchRequestLimit"
+	// CfgRPCMaxResponseBytes caps the size of a response, the calls of a batch past the limit get an error instead
+	CfgRPCMaxResponseBytes = "rpc.maxResponseBytes"
+	// CfgRPCMaxConcurrentCallsPerConn bounds the number of calls served at the same time for a single connection
+	CfgRPCMaxConcurrentCallsPerConn = "rpc.maxConcurrentCallsPerConn"
+
+	// CfgRateLimitEnabled sets whether to throttle the calls to the public http & websocket services
+	CfgRateLimitEnabled = "rateLimit.enabled"
+	// CfgRateLimitIPRequestsPerSec sets the sustained rate of calls allowed per client IP
+	CfgRateLimitIPRequestsPerSec = "rateLimit.ipRequestsPerSec"
+	// CfgRateLimitIPBurst sets the number of calls a client IP can make at once after being idle
+	CfgRateLimitIPBurst = "rateLimit.ipBurst"
+	// CfgRateLimitAPIKeyRequestsPerSec sets the sustained rate of calls allowed per API key, the calls
+	// with an API key are not counted against the quota of the client IP
+	CfgRateLimitAPIKeyRequestsPerSec = "rateLimit.apiKeyRequestsPerSec"
+	// CfgRateLimitAPIKeyBurst sets the number of calls an API key can make at once after being idle
+	CfgRateLimitAPIKeyBurst = "rateLimit.apiKeyBurst"
+	// CfgRateLimitMethodCosts sets the compute units of the expensive methods, the others cost 1. The calls
+	// are charged their compute units against the rate limits, and counted in compute units per API key.
+	CfgRateLimitMethodCosts = "rateLimit.methodCosts"
+	// CfgRateLimitTrustForwardedFor sets whether to identify the clients by the X-Forwarded-For header,
+	// only to be set behind a reverse proxy which overwrites it
+	CfgRateLimitTrustForwardedFor = "rateLimit.trustForwardedFor"
+
+	// CfgAPIKeysEnabled sets whether to check the API keys passed in the X-API-Key header, or in the
+	// request path as /rpc/<key>, and to count the calls of each key
+	CfgAPIKeysEnabled = "apiKeys.enabled"
+	// CfgAPIKeysRequired sets whether to reject the calls without an API key
+	CfgAPIKeysRequired = "apiKeys.required"
+	// CfgAPIKeysKeys lists the API keys, each with a name, a key, and optionally the namespaces it can call
+	// and its own requestsPerSec and burst quota, enforced even if rateLimit.enabled is not set
+	CfgAPIKeysKeys = "apiKeys.keys"
+	// CfgAPIKeysFile sets the path of a YAML or JSON file listing more API keys under "keys", in the same format
+	CfgAPIKeysFile = "apiKeys.file"
+	// CfgAPIKeysReloadIntervalSecs sets how often the API key file is checked for changes
+	CfgAPIKeysReloadIntervalSecs = "apiKeys.reloadIntervalSecs"
+
+	// CfgAuthRPCEnabled sets whether to run the authenticated RPC service, which requires an HS256 JWT
+	// signed with the shared secret, as the Engine API does
+	CfgAuthRPCEnabled = "authrpc.enabled"
+	// CfgAuthRPCAddress sets the binding address of the authenticated RPC service
+	CfgAuthRPCAddress = "authrpc.address"
+	// CfgAuthRPCPort sets the port of the authenticated RPC service, which serves both http and websocket
+	CfgAuthRPCPort = "authrpc.port"
+	// CfgAuthRPCJWTSecretFile sets the path of the hex-encoded 32-byte JWT secret, defaults to <config path>/jwtsecret.
+	// A new secret is generated if the file does not exist.
+	CfgAuthRPCJWTSecretFile = "authrpc.jwtSecretFile"
+	// CfgAuthRPCModules lists the namespaces exposed through the authenticated RPC service
+	CfgAuthRPCModules = "authrpc.modules"
+	// CfgAuthRPCIPCPath sets the path of a Unix socket serving the modules of the authenticated RPC service
+	// without a JWT, the socket is only accessible to the owner. Disabled if empty.
+	CfgAuthRPCIPCPath = "authrpc.ipcPath"
+
+	// CfgMetricsEnabled sets whether to serve the Prometheus metrics at /metrics
+	CfgMetricsEnabled = "metrics.enabled"
+	// CfgMetricsAddress sets the binding address of the metrics service
+	CfgMetricsAddress = "metrics.address"
+	// CfgMetricsPort sets the port of the metrics service
+	CfgMetricsPort = "metrics.port"
+
+	// CfgTracingEnabled sets whether to trace the RPC calls, and the Script RPC calls made to serve them
+	CfgTracingEnabled = "tracing.enabled"
+	// CfgTracingExporter sets where the spans are exported: "otlp" for an OTLP/HTTP collector, "stdout",
+	// or "file"
+	CfgTracingExporter = "tracing.exporter"
+	// CfgTracingOTLPEndpoint sets the URL of the OTLP/HTTP collector, the spans are posted to <url>/v1/traces
+	CfgTracingOTLPEndpoint = "tracing.otlpEndpoint"
+	// CfgTracingOTLPHeaders sets the http headers of the requests to the collector, e.g. for authentication
+	CfgTracingOTLPHeaders = "tracing.otlpHeaders"
+	// CfgTracingFile sets the path of the file the spans are appended to, as lines of JSON. Defaults to
+	// <config path>/traces.jsonl
+	CfgTracingFile = "tracing.file"
+	// CfgTracingSampleRatio sets the ratio of the traces started by the adaptor which are recorded. The calls
+	// with a traceparent header are recorded if the caller sampled them.
+	CfgTracingSampleRatio = "tracing.sampleRatio"
+	// CfgTracingServiceName sets the service name of the exported spans
+	CfgTracingServiceName = "tracing.serviceName"
+
+	// CfgHealthCheckIntervalSecs sets how often the Script node is checked for /ready and /status
+	CfgHealthCheckIntervalSecs = "health.checkIntervalSecs"
+	// CfgHealthMaxBlockAgeSecs sets how old the latest finalized block can get before the adaptor reports
+	// not ready, 0 to ignore the block age
+	CfgHealthMaxBlockAgeSecs = "health.maxBlockAgeSecs"
+
+	// CfgFollowerPollIntervalMillis sets how often the Script node is polled for newly finalized blocks
+	CfgFollowerPollIntervalMillis = "follower.pollIntervalMillis"
+	// CfgFollowerMaxCatchUpBlocks caps the number of missed blocks replayed after the follower fell behind
+	CfgFollowerMaxCatchUpBlocks = "follower.maxCatchUpBlocks"
+
+	// CfgFilterTimeoutSecs sets how long an installed filter is kept without being polled
+	CfgFilterTimeoutSecs = "filter.timeoutSecs"
+
+	// CfgCacheBlocksMB bounds the size of the in-process cache of the finalized Script blocks
+	CfgCacheBlocksMB = "cache.blocksMB"
+	// CfgCacheEthBlocksMB bounds the size of the in-process cache of the blocks converted to the Ethereum format
+	CfgCacheEthBlocksMB = "cache.ethBlocksMB"
+
+	// CfgLogIndexEnabled sets whether to index the logs of the finalized blocks on disk for eth_getLogs
+	CfgLogIndexEnabled = "logIndex.enabled"
+	// CfgLogIndexDBPath sets the path of the log index database, defaults to <config path>/db/logindex
+	CfgLogIndexDBPath = "logIndex.dbPath"
+	// CfgLogIndexStartHeight sets the height the indexer starts from when the index is empty
+	CfgLogIndexStartHeight = "logIndex.startHeight"
+	// CfgLogIndexBatchBlocks sets the number of blocks queried and indexed at a time
+	CfgLogIndexBatchBlocks = "logIndex.batchBlocks"
+
+	// CfgGasPriceBlocks sets the number of recent blocks the gas price oracle suggests a price from
+	CfgGasPriceBlocks = "gasPrice.blocks"
+	// CfgGasPricePercentile sets the percentile of the gas prices of the recent blocks the oracle suggests
+	CfgGasPricePercentile = "gasPrice.percentile"
+	// CfgGasPriceIgnoreOutlierPercent sets the share of the lowest and of the highest gas prices ignored as outliers
+	CfgGasPriceIgnoreOutlierPercent = "gasPrice.ignoreOutlierPercent"
+	// CfgGasPriceFloorWei sets the lowest gas price the oracle suggests, in wei
+	CfgGasPriceFloorWei = "gasPrice.floorWei"
+
+	// CfgEstimateGasMarginPercent sets the safety margin added on top of the gas found by eth_estimateGas
+	CfgEstimateGasMarginPercent = "estimateGas.marginPercent"
+	// CfgEstimateGasMaxIterations caps the number of calls eth_estimateGas makes while searching for the lowest gas limit
+	CfgEstimateGasMaxIterations = "estimateGas.maxIterations"
 
 	// CfgQueryGetLogsBlockRange sets the max block range for the eth_getLogs call
 	CfgQueryGetLogsBlockRange = "query.getLogsBlockRange"
+	// CfgQueryGetLogsMaxResults caps the number of logs an eth_getLogs call served from the log index returns, 0 for no cap
+	CfgQueryGetLogsMaxResults = "query.getLogsMaxResults"
 
 	// CfgLogLevels sets the log level.
 	CfgLogLevels = "log.levels"
@@ -45,16 +207,100 @@ func init() {
 
 	viper.SetDefault(CfgScriptRPCEndpoint, "http://127.0.0.1:16888/rpc")
 	viper.SetDefault(CfgScriptBlockGasLimit, 20000000)
+	viper.SetDefault(CfgScriptRPCTimeoutSecs, 30)
+	viper.SetDefault(CfgScriptRPCMethodTimeoutSecs, map[string]int{"getblocksbyrange": 120})
+	viper.SetDefault(CfgScriptRPCMaxIdleConns, 64)
+	viper.SetDefault(CfgScriptRetryAttempts, 5)
+	viper.SetDefault(CfgScriptRetryBackoffMillis, 1000)
+	viper.SetDefault(CfgScriptRetryMaxBackoffMillis, 6000)
+	viper.SetDefault(CfgScriptExtendedCallMethod, "")
 
 	viper.SetDefault(CfgRPCEnabled, true)
 	viper.SetDefault(CfgRPCHttpAddress, "127.0.0.1")
 	viper.SetDefault(CfgRPCHttpPort, "18888")
 	viper.SetDefault(CfgRPCWSAddress, "127.0.0.1")
 	viper.SetDefault(CfgRPCWSPort, "18889")
+	viper.SetDefault(CfgRPCHttpModules, []string{"net", "eth", "web3", "evm"})
+	viper.SetDefault(CfgRPCWSModules, []string{"net", "eth", "web3", "evm"})
+	viper.SetDefault(CfgRPCCorsOrigins, []string{"*"})
+	viper.SetDefault(CfgRPCWSOrigins, []string{"*"})
+	viper.SetDefault(CfgRPCVirtualHosts, []string{"*"})
+	viper.SetDefault(CfgRPCPrivateMethods, []string{})
 	viper.SetDefault(CfgRPCMaxConnections, 2048)
 	viper.SetDefault(CfgRPCTimeoutSecs, 600)
+	viper.SetDefault(CfgRPCReadTimeoutSecs, 30)
+	viper.SetDefault(CfgRPCIdleTimeoutSecs, 120)
+	viper.SetDefault(CfgRPCWSPingIntervalSecs, 30)
+	viper.SetDefault(CfgRPCShutdownTimeoutSecs, 10)
+	viper.SetDefault(CfgRPCBatchRequestLimit, 100)
+	viper.SetDefault(CfgRPCMaxResponseBytes, 25*1024*1024)
+	viper.SetDefault(CfgRPCMaxConcurrentCallsPerConn, 8)
+
+	viper.SetDefault(CfgRateLimitEnabled, false)
+	viper.SetDefault(CfgRateLimitIPRequestsPerSec, 50)
+	viper.SetDefault(CfgRateLimitIPBurst, 100)
+	viper.SetDefault(CfgRateLimitAPIKeyRequestsPerSec, 200)
+	viper.SetDefault(CfgRateLimitAPIKeyBurst, 400)
+	viper.SetDefault(CfgRateLimitMethodCosts, map[string]int{
+		"eth_getLogs":            20,
+		"eth_getFilterLogs":      20,
+		"eth_feeHistory":         5,
+		"eth_estimateGas":        5,
+		"eth_call":               2,
+		"debug_traceTransaction": 50,
+		"debug_traceCall":        50,
+	})
+	viper.SetDefault(CfgRateLimitTrustForwardedFor, false)
+
+	viper.SetDefault(CfgAPIKeysEnabled, false)
+	viper.SetDefault(CfgAPIKeysRequired, false)
+	viper.SetDefault(CfgAPIKeysFile, "")
+	viper.SetDefault(CfgAPIKeysReloadIntervalSecs, 10)
+
+	viper.SetDefault(CfgAuthRPCEnabled, false)
+	viper.SetDefault(CfgAuthRPCAddress, "127.0.0.1")
+	viper.SetDefault(CfgAuthRPCPort, "18890")
+	viper.SetDefault(CfgAuthRPCJWTSecretFile, "")
+	viper.SetDefault(CfgAuthRPCModules, []string{"net", "eth", "web3", "evm", "debug"})
+	viper.SetDefault(CfgAuthRPCIPCPath, "")
+
+	viper.SetDefault(CfgMetricsEnabled, false)
+	viper.SetDefault(CfgMetricsAddress, "127.0.0.1")
+	viper.SetDefault(CfgMetricsPort, "18891")
+
+	viper.SetDefault(CfgTracingEnabled, false)
+	viper.SetDefault(CfgTracingExporter, "otlp")
+	viper.SetDefault(CfgTracingOTLPEndpoint, "http://127.0.0.1:4318")
+	viper.SetDefault(CfgTracingFile, "")
+	viper.SetDefault(CfgTracingSampleRatio, 1.0)
+	viper.SetDefault(CfgTracingServiceName, "script-eth-rpc-adaptor")
+
+	viper.SetDefault(CfgHealthCheckIntervalSecs, 5)
+	viper.SetDefault(CfgHealthMaxBlockAgeSecs, 60)
+
+	viper.SetDefault(CfgFollowerPollIntervalMillis, 1000)
+	viper.SetDefault(CfgFollowerMaxCatchUpBlocks, 100)
+
+	viper.SetDefault(CfgFilterTimeoutSecs, 300)
+
+	viper.SetDefault(CfgCacheBlocksMB, 256)
+	viper.SetDefault(CfgCacheEthBlocksMB, 64)
+
+	viper.SetDefault(CfgLogIndexEnabled, false)
+	viper.SetDefault(CfgLogIndexDBPath, "")
+	viper.SetDefault(CfgLogIndexStartHeight, 1)
+	viper.SetDefault(CfgLogIndexBatchBlocks, 100)
+
+	viper.SetDefault(CfgGasPriceBlocks, 20)
+	viper.SetDefault(CfgGasPricePercentile, 60)
+	viper.SetDefault(CfgGasPriceIgnoreOutlierPercent, 5)
+	viper.SetDefault(CfgGasPriceFloorWei, "0")
+
+	viper.SetDefault(CfgEstimateGasMarginPercent, 10)
+	viper.SetDefault(CfgEstimateGasMaxIterations, 24)
 
 	viper.SetDefault(CfgQueryGetLogsBlockRange, 5000)
+	viper.SetDefault(CfgQueryGetLogsMaxResults, 10000)
 
 	viper.SetDefault(CfgLogLevels, "*:debug")
 	viper.SetDefault(CfgLogPrintSelfID, false)
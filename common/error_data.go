@@ -0,0 +1,48 @@
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+type errorDataKey struct{}
+
+// errorDataSlot holds the data member of the error returned by a call. The rpc package only
+// writes the code and the message of the errors, so the servers read the data from the slot
+// and add it to the error response themselves.
+type errorDataSlot struct {
+	mu   sync.Mutex
+	data interface{}
+}
+
+// ContextWithErrorData returns a context in which the handler of a call can record the data
+// member of its error, see RecordErrorData
+func ContextWithErrorData(ctx context.Context) context.Context {
+	return context.WithValue(ctx, errorDataKey{}, &errorDataSlot{})
+}
+
+// RecordErrorData keeps the data member of err, if it has one, in the slot of ctx, and returns
+// err unchanged so that the handlers can write `return "", RecordErrorData(ctx, err)`
+func RecordErrorData(ctx context.Context, err error) error {
+	dataErr, ok := err.(interface{ ErrorData() interface{} })
+	if !ok {
+		return err
+	}
+	if slot, ok := ctx.Value(errorDataKey{}).(*errorDataSlot); ok {
+		slot.mu.Lock()
+		slot.data = dataErr.ErrorData()
+		slot.mu.Unlock()
+	}
+	return err
+}
+
+// ErrorData returns the data member recorded in the slot of ctx, nil if there is none
+func ErrorData(ctx context.Context) interface{} {
+	slot, ok := ctx.Value(errorDataKey{}).(*errorDataSlot)
+	if !ok {
+		return nil
+	}
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	return slot.data
+}
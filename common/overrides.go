@@ -0,0 +1,41 @@
+package common
+
+import (
+	"fmt"
+
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/common/hexutil"
+)
+
+// OverrideAccount holds the fields of an account overridden for the duration of a call, in
+// the format of the geth stateOverride parameter of eth_call. State replaces the whole
+// storage of the account, while StateDiff only replaces the given slots.
+type OverrideAccount struct {
+	Nonce     *hexutil.Uint64                `json:"nonce,omitempty"`
+	Code      *hexutil.Bytes                 `json:"code,omitempty"`
+	Balance   *hexutil.Big                   `json:"balance,omitempty"`
+	State     *map[tcommon.Hash]tcommon.Hash `json:"state,omitempty"`
+	StateDiff *map[tcommon.Hash]tcommon.Hash `json:"stateDiff,omitempty"`
+}
+
+// StateOverride is the set of accounts overridden for the duration of a call
+type StateOverride map[tcommon.Address]OverrideAccount
+
+// Validate rejects the overrides geth rejects
+func (s *StateOverride) Validate() error {
+	if s == nil {
+		return nil
+	}
+	for address, account := range *s {
+		if account.State != nil && account.StateDiff != nil {
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", address.Hex())
+		}
+	}
+	return nil
+}
+
+// BlockOverrides holds the fields of the block context overridden for the duration of a call
+type BlockOverrides struct {
+	Number *hexutil.Big    `json:"number,omitempty"`
+	Time   *hexutil.Uint64 `json:"time,omitempty"`
+}
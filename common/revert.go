@@ -0,0 +1,130 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// ErrCodeExecutionReverted is the JSON-RPC error code geth returns for reverted calls
+	ErrCodeExecutionReverted = 3
+
+	// vmErrExecutionReverted is the message of vm.ErrExecutionReverted of the Script ledger, the VM
+	// error script.CallSmartContract and the receipts report for an execution ended by REVERT
+	vmErrExecutionReverted = "evm: execution reverted"
+)
+
+var (
+	// errorSelector is the selector of Error(string), the revert reason of require() and revert()
+	errorSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+	// panicSelector is the selector of Panic(uint256), raised by failing asserts, overflows, etc.
+	panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+
+	errInvalidRevertData = errors.New("invalid revert data")
+)
+
+// panicReasons describes the Solidity panic codes
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// RevertError is a reverted execution, surfaced the way geth does: with the JSON-RPC error
+// code 3, the decoded reason in the message, and the raw revert data in the data member. The
+// rpc package does not write the data member, the handlers record it with RecordErrorData.
+type RevertError struct {
+	reason string // decoded reason, empty if the revert data is not Error(string) or Panic(uint256)
+	data   string // hex encoded revert data
+}
+
+func (e *RevertError) Error() string {
+	if e.reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.reason
+}
+
+// ErrorCode implements the rpc.Error interface
+func (e *RevertError) ErrorCode() int {
+	return ErrCodeExecutionReverted
+}
+
+// ErrorData implements the rpc.DataError interface
+func (e *RevertError) ErrorData() interface{} {
+	return e.data
+}
+
+// Reason returns the decoded revert reason, if any
+func (e *RevertError) Reason() string {
+	return e.reason
+}
+
+// IsRevert tells whether the VM error returned by script.CallSmartContract is a revert
+func IsRevert(vmError string) bool {
+	return vmError == vmErrExecutionReverted
+}
+
+// NewVMError converts the VM error and the hex encoded return data of an execution into
+// an error. Reverts become a RevertError, the other VM errors are returned as is.
+func NewVMError(vmError string, vmReturn string) error {
+	if !IsRevert(vmError) {
+		return errors.New(vmError)
+	}
+
+	revertData, err := hex.DecodeString(strings.TrimPrefix(vmReturn, "0x"))
+	if err != nil {
+		revertData = []byte{}
+	}
+	reason, _ := UnpackRevert(revertData)
+	return &RevertError{reason: reason, data: "0x" + hex.EncodeToString(revertData)}
+}
+
+// UnpackRevert decodes the revert data of an Error(string) or a Panic(uint256)
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errInvalidRevertData
+	}
+
+	selector, args := data[:4], data[4:]
+	switch {
+	case bytes.Equal(selector, errorSelector):
+		// abi.encode(string): the offset of the string, its length, then its bytes
+		if len(args) < 64 {
+			return "", errInvalidRevertData
+		}
+		offset := new(big.Int).SetBytes(args[:32])
+		if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(args)) {
+			return "", errInvalidRevertData
+		}
+		start := offset.Uint64() + 32
+		length := new(big.Int).SetBytes(args[offset.Uint64():start])
+		if !length.IsUint64() || start+length.Uint64() > uint64(len(args)) {
+			return "", errInvalidRevertData
+		}
+		return string(args[start : start+length.Uint64()]), nil
+	case bytes.Equal(selector, panicSelector):
+		if len(args) < 32 {
+			return "", errInvalidRevertData
+		}
+		code := new(big.Int).SetBytes(args[:32])
+		if code.IsUint64() {
+			if reason, ok := panicReasons[code.Uint64()]; ok {
+				return reason, nil
+			}
+		}
+		return fmt.Sprintf("unknown panic code: %#x", code), nil
+	}
+	return "", errInvalidRevertData
+}
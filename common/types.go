@@ -28,6 +28,9 @@ type EthGetTransactionResult struct {
 	V                hexutil.Uint64   `json:"v"` //ECDSA recovery id
 	R                tcommon.Hash     `json:"r"` //ECDSA signature r
 	S                tcommon.Hash     `json:"s"` //ECDSA signature s
+
+	// Script txs are legacy txs, they carry gasPrice but none of the EIP-1559 fee fields
+	Type hexutil.Uint64 `json:"type"`
 }
 
 type EthGetBlockResult struct {
@@ -51,6 +54,7 @@ type EthGetBlockResult struct {
 	ExtraData       string         `json:"extraData"`
 	Uncles          []tcommon.Hash `json:"uncles"`
 	Transactions    []interface{}  `json:"transactions"`
+	BaseFeePerGas   string         `json:"baseFeePerGas"`
 }
 
 type EthSyncingResult struct {
@@ -74,6 +78,8 @@ type EthGetReceiptResult struct {
 	Logs              []EthLogObj     `json:"logs"`
 	LogsBloom         string          `json:"logsBloom"`
 	Status            hexutil.Uint64  `json:"status"`
+	Type              hexutil.Uint64  `json:"type"`
+	EffectiveGasPrice string          `json:"effectiveGasPrice,omitempty"`
 }
 
 type Tx struct {
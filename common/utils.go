@@ -2,7 +2,6 @@ package common
 
 import (
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -16,9 +15,7 @@ import (
 	tcommon "github.com/scripttoken/script/common"
 	"github.com/scripttoken/script/crypto"
 	"github.com/scripttoken/script/ledger/types"
-	trpc "github.com/scripttoken/script/rpc"
 	log "github.com/sirupsen/logrus"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 var logger *log.Entry = log.WithFields(log.Fields{"prefix": "common"})
@@ -33,35 +30,13 @@ func GetScriptRPCEndpoint() string {
 	return scriptRPCEndpoint
 }
 
-func HandleScriptRPCResponse(rpcRes *rpcc.RPCResponse, rpcErr error, parse func(jsonBytes []byte) (interface{}, error)) (result interface{}, err error) {
-	if rpcErr != nil {
-		return nil, fmt.Errorf("failed to get script RPC response: %v", rpcErr)
-	}
-	if rpcRes.Error != nil {
-		return nil, fmt.Errorf("script RPC returns an error: %v", rpcRes.Error)
-	}
-
-	var jsonBytes []byte
-	jsonBytes, err = json.MarshalIndent(rpcRes.Result, "", "    ")
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse script RPC response: %v, %s", err, string(jsonBytes))
-	}
-
-	//logger.Infof("HandleScriptRPCResponse, jsonBytes: %v", string(jsonBytes))
-	result, err = parse(jsonBytes)
-	if err != nil {
-		logger.Warnf("Failed to parse script RPC response: %v, %s", err, string(jsonBytes))
-	}
-	return
-}
-
 func GetHeightByTag(tag string) (height tcommon.JSONUint64) {
 	switch tag {
 	case "latest":
 		height = tcommon.JSONUint64(math.MaxUint64)
 	case "earliest":
 		height = tcommon.JSONUint64(1)
-	case "pending":
+	case "pending", "safe", "finalized":
 		height = tcommon.JSONUint64(math.MaxUint64)
 	default:
 		height = tcommon.JSONUint64(Str2hex2unit(tag))
@@ -108,16 +83,9 @@ func HexToBytes(hexStr string) ([]byte, error) {
 	return data, err
 }
 
-func GenerateSctx(arg EthSmartContractArgObj) (result *types.SmartContractTx, err error) {
-	sequence, seqErr := GetSeqByAddress(arg.From)
-	if seqErr != nil {
-		logger.Errorf("Failed to get sequence by address: %v\n", arg.From)
-		if arg.From.String() != "0x0000000000000000000000000000000000000000" {
-			return nil, seqErr
-		}
-		sequence = 1
-	}
-
+// GenerateSctx builds the smart contract transaction for the given call, sequence is the
+// sequence of the sender account the transaction should use
+func GenerateSctx(arg EthSmartContractArgObj, sequence uint64) (result *types.SmartContractTx, err error) {
 	from := types.TxInput{
 		Address: arg.From, //tcommon.HexToAddress(arg.From.String()),
 		Coins: types.Coins{
@@ -154,7 +122,7 @@ func GenerateSctx(arg EthSmartContractArgObj) (result *types.SmartContractTx, er
 	if arg.Gas != "" {
 		gas = Str2hex2unit(arg.Gas)
 	}
-	fmt.Printf("gas: %v\n", gas)
+	logger.Debugf("gas: %v", gas)
 
 	result = &types.SmartContractTx{
 		From:     from,
@@ -175,9 +143,9 @@ func SignRawBytes(address string, rawBytes []byte) (result *crypto.Signature, er
 	return
 }
 
-func GetSignedBytes(arg EthSmartContractArgObj, chainID string, blockNumber string) (string, error) {
+func GetSignedBytes(arg EthSmartContractArgObj, sequence uint64, chainID string, blockNumber string) (string, error) {
 	fromAddress := tcommon.HexToAddress(arg.From.String())
-	sctx, _ := GenerateSctx(arg)
+	sctx, _ := GenerateSctx(arg, sequence)
 	sctxSignBytes := sctx.SignBytes(MapChainID(chainID, blockNumber))
 	signature, err := SignRawBytes(strings.ToLower(arg.From.String()), sctxSignBytes)
 	if err != nil {
@@ -194,8 +162,8 @@ func GetSignedBytes(arg EthSmartContractArgObj, chainID string, blockNumber stri
 	return signedTXstr, nil
 }
 
-func GetSctxBytes(arg EthSmartContractArgObj) (sctxBytes []byte, err error) {
-	sctx, err := GenerateSctx(arg)
+func GetSctxBytes(arg EthSmartContractArgObj, sequence uint64) (sctxBytes []byte, err error) {
+	sctx, err := GenerateSctx(arg, sequence)
 	if err != nil {
 		logger.Errorf("Failed to generate smart contract transaction: %v\n", sctx)
 		return sctxBytes, err
@@ -208,46 +176,6 @@ func GetSctxBytes(arg EthSmartContractArgObj) (sctxBytes []byte, err error) {
 	return sctxBytes, nil
 }
 
-func GetSeqByAddress(address tcommon.Address) (sequence uint64, err error) {
-	client := rpcc.NewRPCClient(GetScriptRPCEndpoint())
-
-	rpcRes, rpcErr := client.Call("script.GetAccount", trpc.GetAccountArgs{Address: address.String()})
-
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		trpcResult := trpc.GetAccountResult{Account: &types.Account{}}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		return trpcResult.Account.Sequence, nil
-	}
-
-	resultIntf, err := HandleScriptRPCResponse(rpcRes, rpcErr, parse)
-
-	if err != nil {
-		//return sequence, err
-		return 1, nil
-	}
-	sequence = resultIntf.(uint64) + 1
-
-	return sequence, nil
-}
-
-func GetCurrentHeight() (height tcommon.JSONUint64, err error) {
-	client := rpcc.NewRPCClient(GetScriptRPCEndpoint())
-	rpcRes, rpcErr := client.Call("script.GetStatus", trpc.GetStatusArgs{})
-
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		trpcResult := trpc.GetStatusResult{}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		return trpcResult.LatestFinalizedBlockHeight, nil
-	}
-
-	resultIntf, err := HandleScriptRPCResponse(rpcRes, rpcErr, parse)
-	if err != nil {
-		return height, err
-	}
-	height = resultIntf.(tcommon.JSONUint64)
-	return height, nil
-}
-
 func MapChainID(chainIDStr string, blockNumber string) string {
 	if HexStr2Uint64(blockNumber) < tcommon.HeightRPCCompatibility {
 		return mapChainIDWithoutOffset(chainIDStr)
@@ -0,0 +1,304 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "evm"})
+
+// Message is a call or a contract creation to execute
+type Message struct {
+	From     gethcommon.Address
+	To       *gethcommon.Address // nil for a contract creation
+	Value    *big.Int
+	Gas      uint64
+	GasPrice *big.Int
+	Data     []byte
+}
+
+// NewMessage returns the message of a smart contract transaction
+func NewMessage(tx *types.SmartContractTx) Message {
+	msg := Message{
+		From:     gethcommon.Address(tx.From.Address),
+		Value:    new(big.Int),
+		Gas:      tx.GasLimit,
+		GasPrice: new(big.Int),
+		Data:     tx.Data,
+	}
+	if (tx.To.Address != tcommon.Address{}) {
+		to := gethcommon.Address(tx.To.Address)
+		msg.To = &to
+	}
+	if tx.From.Coins.SPAYWei != nil {
+		msg.Value.Set(tx.From.Coins.SPAYWei)
+	}
+	if tx.GasPrice != nil {
+		msg.GasPrice.Set(tx.GasPrice)
+	}
+	return msg
+}
+
+// BlockContext is the block the messages are executed in
+type BlockContext struct {
+	Number   *big.Int
+	Time     uint64
+	Coinbase gethcommon.Address
+	GasLimit uint64
+}
+
+// NewBlockContext returns the context of the given Script block
+func NewBlockContext(block *common.ScriptGetBlockResultInner) BlockContext {
+	blockCtx := BlockContext{
+		Number:   new(big.Int).SetUint64(uint64(block.Height)),
+		Coinbase: gethcommon.Address(block.Proposer),
+		GasLimit: viper.GetUint64(common.CfgScriptBlockGasLimit),
+	}
+	if block.Timestamp != nil {
+		blockCtx.Time = block.Timestamp.ToInt().Uint64()
+	}
+	return blockCtx
+}
+
+// ApplyOverrides replaces the given fields of the block
+func (c *BlockContext) ApplyOverrides(overrides *common.BlockOverrides) {
+	if overrides == nil {
+		return
+	}
+	if overrides.Number != nil {
+		c.Number = new(big.Int).Set(overrides.Number.ToInt())
+	}
+	if overrides.Time != nil {
+		c.Time = uint64(*overrides.Time)
+	}
+}
+
+// Result is the outcome of a message
+type Result struct {
+	UsedGas    uint64
+	ReturnData []byte
+	Err        error // the VM error, e.g. vm.ErrExecutionReverted, nil if the execution succeeded
+}
+
+// Failed tells whether the execution failed
+func (r *Result) Failed() bool {
+	return r.Err != nil
+}
+
+// Executor executes messages with geth's EVM against a StateDB. The EVM follows the rules of
+// Istanbul, the last Ethereum fork it implements, and has the Ethereum precompiles only: the
+// gas used may differ from the one charged by the Script node for the same message.
+type Executor struct {
+	ctx     context.Context
+	backend backend.ScriptBackend
+	state   *StateDB
+	block   BlockContext
+	config  *params.ChainConfig
+
+	hashes map[uint64]gethcommon.Hash // of the blocks read by BLOCKHASH
+}
+
+// NewExecutor creates an executor running the messages in the given block, one after the other
+func NewExecutor(ctx context.Context, b backend.ScriptBackend, state *StateDB, block BlockContext) (*Executor, error) {
+	chainID, err := backend.GetEthChainID(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Executor{
+		ctx:     ctx,
+		backend: b,
+		state:   state,
+		block:   block,
+		config: &params.ChainConfig{
+			ChainID:             new(big.Int).SetUint64(chainID),
+			HomesteadBlock:      new(big.Int),
+			EIP150Block:         new(big.Int),
+			EIP155Block:         new(big.Int),
+			EIP158Block:         new(big.Int),
+			ByzantiumBlock:      new(big.Int),
+			ConstantinopleBlock: new(big.Int),
+			PetersburgBlock:     new(big.Int),
+			IstanbulBlock:       new(big.Int),
+		},
+		hashes: make(map[uint64]gethcommon.Hash),
+	}, nil
+}
+
+// NewCallExecutor creates an executor for the calls at the given block parameter, see
+// common.BlockRef: the calls start from the state after the block, and run in its context
+func NewCallExecutor(ctx context.Context, b backend.ScriptBackend, ref *common.BlockRef) (*Executor, error) {
+	height, isLatest, err := backend.ResolveBlockRef(ctx, b, ref)
+	if err != nil {
+		return nil, err
+	}
+	if isLatest {
+		if height, err = backend.GetCurrentHeight(ctx, b); err != nil {
+			return nil, err
+		}
+	}
+
+	block, err := b.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	return NewExecutor(ctx, b, NewStateDB(ctx, b, height), NewBlockContext(block))
+}
+
+// ApplyOverrides replaces the given fields of the accounts and of the block before the
+// messages are executed, as the overrides of eth_call
+func (e *Executor) ApplyOverrides(overrides *common.StateOverride, blockOverrides *common.BlockOverrides) error {
+	if err := e.state.ApplyOverrides(overrides); err != nil {
+		return err
+	}
+	e.block.ApplyOverrides(blockOverrides)
+	return e.state.Error()
+}
+
+// Apply executes the message, and commits its writes so that the next message starts from
+// them. The tracer, if not nil, is notified of every opcode. An error is returned if the
+// message cannot be executed at all, e.g. when the sender cannot pay for the gas, or when
+// the state cannot be fetched; the failures of the execution itself are in Result.Err.
+func (e *Executor) Apply(msg Message, tracer vm.Tracer) (*Result, error) {
+	vmConfig := vm.Config{}
+	if tracer != nil {
+		vmConfig.Debug = true
+		vmConfig.Tracer = tracer
+	}
+	evm := vm.NewEVM(vm.Context{
+		CanTransfer: canTransfer,
+		Transfer:    transfer,
+		GetHash:     e.getHash,
+		Origin:      msg.From,
+		GasPrice:    new(big.Int).Set(msg.GasPrice),
+		Coinbase:    e.block.Coinbase,
+		GasLimit:    e.block.GasLimit,
+		BlockNumber: new(big.Int).Set(e.block.Number),
+		Time:        new(big.Int).SetUint64(e.block.Time),
+		Difficulty:  new(big.Int),
+	}, e.state, e.config, vmConfig)
+
+	// Abort the execution as soon as the caller gives up, e.g. on an infinite loop
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-e.ctx.Done():
+			evm.Cancel()
+		case <-done:
+		}
+	}()
+
+	gasCost := new(big.Int).Mul(new(big.Int).SetUint64(msg.Gas), msg.GasPrice)
+	if e.state.GetBalance(msg.From).Cmp(gasCost) < 0 {
+		return nil, e.fail(fmt.Errorf("insufficient funds for gas * price: address %v", msg.From.Hex()))
+	}
+	e.state.SubBalance(msg.From, gasCost)
+
+	intrinsicGas := intrinsicGas(msg.Data, msg.To == nil)
+	if msg.Gas < intrinsicGas {
+		return nil, e.fail(fmt.Errorf("intrinsic gas too low: have %v, want %v", msg.Gas, intrinsicGas))
+	}
+
+	var (
+		sender     = vm.AccountRef(msg.From)
+		returnData []byte
+		gasLeft    uint64
+		vmErr      error
+	)
+	if msg.To == nil {
+		returnData, _, gasLeft, vmErr = evm.Create(sender, msg.Data, msg.Gas-intrinsicGas, msg.Value)
+	} else {
+		e.state.SetNonce(msg.From, e.state.GetNonce(msg.From)+1)
+		returnData, gasLeft, vmErr = evm.Call(sender, *msg.To, msg.Data, msg.Gas-intrinsicGas, msg.Value)
+	}
+	if vmErr == vm.ErrInsufficientBalance {
+		return nil, e.fail(fmt.Errorf("insufficient funds for transfer: address %v", msg.From.Hex()))
+	}
+
+	// Refund the unused gas and up to half of the used gas for the cleared slots
+	refund := (msg.Gas - gasLeft) / 2
+	if refund > e.state.GetRefund() {
+		refund = e.state.GetRefund()
+	}
+	gasLeft += refund
+	usedGas := msg.Gas - gasLeft
+	e.state.AddBalance(msg.From, new(big.Int).Mul(new(big.Int).SetUint64(gasLeft), msg.GasPrice))
+	e.state.AddBalance(e.block.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(usedGas), msg.GasPrice))
+
+	if err := e.fail(nil); err != nil {
+		return nil, err
+	}
+	e.state.Finalise()
+
+	return &Result{UsedGas: usedGas, ReturnData: returnData, Err: vmErr}, nil
+}
+
+// fail returns the error which prevents the message from being executed, the error of the
+// state or of the context first as they may have caused err
+func (e *Executor) fail(err error) error {
+	if stateErr := e.state.Error(); stateErr != nil {
+		logger.Warnf("Failed to fetch the state at height %v: %v", e.state.height, stateErr)
+		return stateErr
+	}
+	if ctxErr := e.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// getHash returns the hash of the block at the given height, for BLOCKHASH
+func (e *Executor) getHash(height uint64) gethcommon.Hash {
+	if hash, ok := e.hashes[height]; ok {
+		return hash
+	}
+
+	var hash gethcommon.Hash
+	block, err := e.backend.GetBlockByHeight(e.ctx, tcommon.JSONUint64(height))
+	if err != nil && !errors.Is(err, backend.ErrEmptyBlock) {
+		e.state.setError(err)
+		return hash
+	}
+	if block != nil {
+		hash = gethcommon.Hash(block.Hash)
+	}
+	e.hashes[height] = hash
+	return hash
+}
+
+// intrinsicGas returns the gas charged before the execution, as per Istanbul
+func intrinsicGas(data []byte, contractCreation bool) uint64 {
+	gas := params.TxGas
+	if contractCreation {
+		gas = params.TxGasContractCreation
+	}
+	for _, b := range data {
+		if b == 0 {
+			gas += params.TxDataZeroGas
+		} else {
+			gas += params.TxDataNonZeroGasEIP2028
+		}
+	}
+	return gas
+}
+
+func canTransfer(db vm.StateDB, address gethcommon.Address, amount *big.Int) bool {
+	return db.GetBalance(address).Cmp(amount) >= 0
+}
+
+func transfer(db vm.StateDB, sender, recipient gethcommon.Address, amount *big.Int) {
+	db.SubBalance(sender, amount)
+	db.AddBalance(recipient, amount)
+}
@@ -0,0 +1,230 @@
+package evm
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/common/hexutil"
+	"github.com/scripttoken/script/ledger/types"
+	trpc "github.com/scripttoken/script/rpc"
+	rpcc "github.com/ybbus/jsonrpc"
+)
+
+// mockBackend serves the state of a few accounts, the other methods of ScriptBackend are not
+// used by the tests and panic
+type mockBackend struct {
+	backend.ScriptBackend
+
+	balances map[string]*big.Int
+	codes    map[string][]byte
+	storage  map[string]string // by address and slot
+
+	storageReads int
+}
+
+func (b *mockBackend) GetStatus(ctx context.Context) (*trpc.GetStatusResult, error) {
+	return &trpc.GetStatusResult{ChainID: "privatenet", LatestFinalizedBlockHeight: 100}, nil
+}
+
+func (b *mockBackend) GetAccount(ctx context.Context, address string, height tcommon.JSONUint64, preview bool) (*types.Account, error) {
+	balance, ok := b.balances[address]
+	if !ok {
+		return nil, &backend.RPCError{Method: "script.GetAccount",
+			Err: &rpcc.RPCError{Code: -32000, Message: "Account with address " + address + " is not found"}}
+	}
+	account := &types.Account{Balance: types.Coins{SPAYWei: balance}}
+	if code, ok := b.codes[address]; ok {
+		account.CodeHash = tcommon.BytesToHash(crypto.Keccak256(code))
+	}
+	return account, nil
+}
+
+func (b *mockBackend) GetCode(ctx context.Context, address string, height tcommon.JSONUint64) (string, error) {
+	return hex.EncodeToString(b.codes[address]), nil
+}
+
+func (b *mockBackend) GetStorageAt(ctx context.Context, address string, storagePosition string, height tcommon.JSONUint64) (string, error) {
+	b.storageReads++
+	value, ok := b.storage[address+storagePosition]
+	if !ok {
+		return fmt.Sprintf("%064x", 0), nil
+	}
+	return value, nil
+}
+
+func TestExecutorApply(t *testing.T) {
+	sender := gethcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	counter := gethcommon.HexToAddress("0x2000000000000000000000000000000000000002")
+	reverter := gethcommon.HexToAddress("0x3000000000000000000000000000000000000003")
+	slot0 := gethcommon.Hash{}.Hex()
+
+	b := &mockBackend{
+		balances: map[string]*big.Int{
+			sender.Hex():   big.NewInt(1e18),
+			counter.Hex():  new(big.Int),
+			reverter.Hex(): new(big.Int),
+		},
+		codes: map[string][]byte{
+			// slot 0 += 1, return slot 0
+			counter.Hex(): {0x60, 0x00, 0x54, 0x60, 0x01, 0x01, 0x80, 0x60, 0x00, 0x55, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3},
+			// revert()
+			reverter.Hex(): {0x60, 0x00, 0x60, 0x00, 0xfd},
+		},
+		storage: map[string]string{
+			counter.Hex() + slot0: fmt.Sprintf("%064x", 41),
+		},
+	}
+
+	ctx := context.Background()
+	executor, err := NewExecutor(ctx, b, NewStateDB(ctx, b, 99), BlockContext{Number: big.NewInt(100), GasLimit: 20000000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		to         gethcommon.Address
+		gasPrice   int64
+		wantReturn int64
+		wantFailed bool
+		wantErr    bool
+	}{
+		{name: "first increment", to: counter, wantReturn: 42},
+		{name: "second increment starts from the first", to: counter, gasPrice: 1, wantReturn: 43},
+		{name: "revert", to: reverter, wantFailed: true},
+		{name: "gas not affordable", to: counter, gasPrice: 1e18, wantErr: true},
+	}
+
+	for _, test := range tests {
+		to := test.to
+		msg := Message{From: sender, To: &to, Value: new(big.Int), Gas: 100000, GasPrice: big.NewInt(test.gasPrice)}
+		result, err := executor.Apply(msg, nil)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: Apply() error = %v, wantErr %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if result.Failed() != test.wantFailed {
+			t.Errorf("%v: Apply() failed = %v, want %v", test.name, result.Err, test.wantFailed)
+		}
+		if result.UsedGas == 0 || result.UsedGas > msg.Gas {
+			t.Errorf("%v: Apply() used gas = %v", test.name, result.UsedGas)
+		}
+		if !test.wantFailed && new(big.Int).SetBytes(result.ReturnData).Int64() != test.wantReturn {
+			t.Errorf("%v: Apply() = %x, want %v", test.name, result.ReturnData, test.wantReturn)
+		}
+	}
+
+	if b.storageReads != 1 {
+		t.Errorf("slot 0 read %v times from the backend, want 1", b.storageReads)
+	}
+	if nonce := executor.state.GetNonce(sender); nonce != 3 {
+		t.Errorf("sender nonce = %v, want 3", nonce)
+	}
+}
+
+func TestStateDBRevertToSnapshot(t *testing.T) {
+	address := gethcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	b := &mockBackend{balances: map[string]*big.Int{address.Hex(): big.NewInt(10)}}
+	state := NewStateDB(context.Background(), b, 0)
+
+	key, value := gethcommon.HexToHash("0x01"), gethcommon.HexToHash("0x02")
+	snapshot := state.Snapshot()
+	state.AddBalance(address, big.NewInt(5))
+	state.SetState(address, key, value)
+	state.SetNonce(address, 9)
+	if state.GetBalance(address).Int64() != 15 || state.GetState(address, key) != value || state.GetNonce(address) != 9 {
+		t.Fatalf("writes not applied")
+	}
+
+	state.RevertToSnapshot(snapshot)
+	if balance := state.GetBalance(address).Int64(); balance != 10 {
+		t.Errorf("balance = %v after revert, want 10", balance)
+	}
+	if got := state.GetState(address, key); got != (gethcommon.Hash{}) {
+		t.Errorf("slot = %v after revert, want 0", got.Hex())
+	}
+	if nonce := state.GetNonce(address); nonce != 0 {
+		t.Errorf("nonce = %v after revert, want 0", nonce)
+	}
+	if state.Error() != nil {
+		t.Errorf("Error() = %v", state.Error())
+	}
+
+	unknown := gethcommon.HexToAddress("0x2000000000000000000000000000000000000002")
+	if state.Exist(unknown) {
+		t.Errorf("unknown account exists")
+	}
+}
+
+func TestExecutorApplyOverrides(t *testing.T) {
+	sender := gethcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	contract := gethcommon.HexToAddress("0x2000000000000000000000000000000000000002")
+	b := &mockBackend{balances: map[string]*big.Int{sender.Hex(): new(big.Int)}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		code       []byte
+		stateDiff  map[tcommon.Hash]tcommon.Hash
+		state      map[tcommon.Hash]tcommon.Hash
+		wantReturn int64
+		wantErr    bool
+	}{
+		// return(number(), 32)
+		{name: "block number", code: []byte{0x43, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3}, wantReturn: 1000},
+		// return(sload(0), 32)
+		{name: "state diff", code: []byte{0x60, 0x00, 0x54, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3},
+			stateDiff: map[tcommon.Hash]tcommon.Hash{{}: tcommon.BytesToHash([]byte{5})}, wantReturn: 5},
+		{name: "state and state diff", code: []byte{0x00},
+			state: map[tcommon.Hash]tcommon.Hash{}, stateDiff: map[tcommon.Hash]tcommon.Hash{}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		executor, err := NewExecutor(ctx, b, NewStateDB(ctx, b, 99), BlockContext{Number: big.NewInt(100), GasLimit: 20000000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := hexutil.Bytes(test.code)
+		account := common.OverrideAccount{Code: &code}
+		if test.state != nil {
+			account.State = &test.state
+		}
+		if test.stateDiff != nil {
+			account.StateDiff = &test.stateDiff
+		}
+		overrides := common.StateOverride{tcommon.Address(contract): account}
+		blockOverrides := &common.BlockOverrides{Number: (*hexutil.Big)(big.NewInt(1000))}
+		err = executor.ApplyOverrides(&overrides, blockOverrides)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: ApplyOverrides() error = %v, wantErr %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		msg := Message{From: sender, To: &contract, Value: new(big.Int), Gas: 100000, GasPrice: new(big.Int)}
+		result, err := executor.Apply(msg, nil)
+		if err != nil || result.Failed() {
+			t.Errorf("%v: Apply() error = %v, %v", test.name, err, result)
+			continue
+		}
+		if got := new(big.Int).SetBytes(result.ReturnData).Int64(); got != test.wantReturn {
+			t.Errorf("%v: Apply() = %v, want %v", test.name, got, test.wantReturn)
+		}
+	}
+	if b.storageReads != 0 {
+		t.Errorf("overridden slot read %v times from the backend, want 0", b.storageReads)
+	}
+}
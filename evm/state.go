@@ -0,0 +1,410 @@
+package evm
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+)
+
+// emptyCodeHash is the code hash of the accounts without code
+var emptyCodeHash = crypto.Keccak256Hash(nil)
+
+// stateObject is an account as seen by the execution. The balance, nonce and code are loaded
+// when the account is first accessed, the storage slot by slot.
+type stateObject struct {
+	exists   bool
+	balance  *big.Int
+	nonce    uint64
+	code     []byte
+	codeHash gethcommon.Hash
+	suicided bool
+
+	origin      map[gethcommon.Hash]gethcommon.Hash // slots before the current message
+	dirty       map[gethcommon.Hash]gethcommon.Hash // slots written by the current message
+	storageWipe bool                                // the slots missing from origin are zero rather than fetched
+}
+
+func newStateObject() *stateObject {
+	return &stateObject{
+		balance:  new(big.Int),
+		codeHash: emptyCodeHash,
+		origin:   make(map[gethcommon.Hash]gethcommon.Hash),
+		dirty:    make(map[gethcommon.Hash]gethcommon.Hash),
+	}
+}
+
+// StateDB implements the state of geth's EVM on top of the state of a Script node at a given
+// height. The accounts, codes and storage slots are fetched when the execution first reads
+// them, and the writes are kept in memory. The StateDB never writes to the Script node.
+//
+// vm.StateDB has no way to report errors, so the first upstream error is kept, and the
+// execution must be discarded if Error returns one.
+type StateDB struct {
+	ctx     context.Context
+	backend backend.ScriptBackend
+	height  tcommon.JSONUint64 // 0 is the latest height
+
+	objects map[gethcommon.Address]*stateObject
+	journal []func() // undoes the writes, in the order they were made
+	refund  uint64
+	logs    []*gethtypes.Log
+	err     error
+}
+
+// NewStateDB creates a state reading the accounts of the Script node at the given height
+func NewStateDB(ctx context.Context, b backend.ScriptBackend, height tcommon.JSONUint64) *StateDB {
+	return &StateDB{
+		ctx:     ctx,
+		backend: b,
+		height:  height,
+		objects: make(map[gethcommon.Address]*stateObject),
+	}
+}
+
+// Error returns the first error met while fetching the state
+func (s *StateDB) Error() error {
+	return s.err
+}
+
+func (s *StateDB) setError(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// Logs returns the logs emitted by the current message
+func (s *StateDB) Logs() []*gethtypes.Log {
+	return s.logs
+}
+
+// Finalise commits the writes of the current message, they become the state the next
+// message starts from
+func (s *StateDB) Finalise() {
+	for address, obj := range s.objects {
+		if obj.suicided {
+			wiped := newStateObject()
+			wiped.storageWipe = true
+			s.objects[address] = wiped
+			continue
+		}
+		for key, value := range obj.dirty {
+			obj.origin[key] = value
+		}
+		obj.dirty = make(map[gethcommon.Hash]gethcommon.Hash)
+	}
+	s.journal = nil
+	s.refund = 0
+	s.logs = nil
+}
+
+// ApplyOverrides replaces the given fields of the accounts before the execution. The
+// overrides are part of the state the messages start from, they cannot be reverted.
+func (s *StateDB) ApplyOverrides(overrides *common.StateOverride) error {
+	if err := overrides.Validate(); err != nil {
+		return err
+	}
+	if overrides == nil {
+		return nil
+	}
+	for address, account := range *overrides {
+		obj := s.getOrNewObject(gethcommon.Address(address))
+		if account.Nonce != nil {
+			obj.nonce = uint64(*account.Nonce)
+		}
+		if account.Code != nil {
+			obj.code = *account.Code
+			obj.codeHash = crypto.Keccak256Hash(obj.code)
+		}
+		if account.Balance != nil {
+			obj.balance = new(big.Int).Set((*big.Int)(account.Balance))
+		}
+		if account.State != nil {
+			obj.origin = make(map[gethcommon.Hash]gethcommon.Hash)
+			obj.storageWipe = true
+			for key, value := range *account.State {
+				obj.origin[gethcommon.Hash(key)] = gethcommon.Hash(value)
+			}
+		}
+		if account.StateDiff != nil {
+			for key, value := range *account.StateDiff {
+				obj.origin[gethcommon.Hash(key)] = gethcommon.Hash(value)
+			}
+		}
+	}
+	s.journal = nil
+	return nil
+}
+
+// getObject returns the account, fetched from the Script node on first access
+func (s *StateDB) getObject(address gethcommon.Address) *stateObject {
+	if obj, ok := s.objects[address]; ok {
+		return obj
+	}
+
+	obj := newStateObject()
+	s.objects[address] = obj
+	if s.err != nil {
+		return obj
+	}
+
+	account, err := s.backend.GetAccount(s.ctx, address.Hex(), s.height, false)
+	if backend.IsAccountNotFound(err) {
+		return obj
+	}
+	if err != nil {
+		s.setError(err)
+		return obj
+	}
+	obj.exists = true
+	obj.nonce = account.Sequence
+	if account.Balance.SPAYWei != nil {
+		obj.balance = new(big.Int).Set(account.Balance.SPAYWei)
+	}
+
+	if codeHash := gethcommon.Hash(account.CodeHash); codeHash == (gethcommon.Hash{}) || codeHash == emptyCodeHash {
+		return obj
+	}
+	codeHex, err := s.backend.GetCode(s.ctx, address.Hex(), s.height)
+	if err != nil {
+		s.setError(err)
+		return obj
+	}
+	code, err := hex.DecodeString(strings.TrimPrefix(codeHex, "0x"))
+	if err != nil {
+		s.setError(err)
+		return obj
+	}
+	obj.code = code
+	obj.codeHash = crypto.Keccak256Hash(code)
+	return obj
+}
+
+// getOrNewObject returns the account, which exists afterwards
+func (s *StateDB) getOrNewObject(address gethcommon.Address) *stateObject {
+	obj := s.getObject(address)
+	if !obj.exists {
+		s.journal = append(s.journal, func() { obj.exists = false })
+		obj.exists = true
+	}
+	return obj
+}
+
+// CreateAccount implements vm.StateDB
+func (s *StateDB) CreateAccount(address gethcommon.Address) {
+	prev := s.getObject(address)
+	obj := newStateObject()
+	obj.exists = true
+	obj.balance.Set(prev.balance)
+	obj.storageWipe = true
+	s.objects[address] = obj
+	s.journal = append(s.journal, func() { s.objects[address] = prev })
+}
+
+// SubBalance implements vm.StateDB
+func (s *StateDB) SubBalance(address gethcommon.Address, amount *big.Int) {
+	s.setBalance(s.getOrNewObject(address), new(big.Int).Sub(s.GetBalance(address), amount))
+}
+
+// AddBalance implements vm.StateDB
+func (s *StateDB) AddBalance(address gethcommon.Address, amount *big.Int) {
+	s.setBalance(s.getOrNewObject(address), new(big.Int).Add(s.GetBalance(address), amount))
+}
+
+func (s *StateDB) setBalance(obj *stateObject, balance *big.Int) {
+	prev := obj.balance
+	s.journal = append(s.journal, func() { obj.balance = prev })
+	obj.balance = balance
+}
+
+// GetBalance implements vm.StateDB
+func (s *StateDB) GetBalance(address gethcommon.Address) *big.Int {
+	return new(big.Int).Set(s.getObject(address).balance)
+}
+
+// GetNonce implements vm.StateDB. The nonce of an account is its Script sequence, i.e. the
+// number of transactions it sent.
+func (s *StateDB) GetNonce(address gethcommon.Address) uint64 {
+	return s.getObject(address).nonce
+}
+
+// SetNonce implements vm.StateDB
+func (s *StateDB) SetNonce(address gethcommon.Address, nonce uint64) {
+	obj := s.getOrNewObject(address)
+	prev := obj.nonce
+	s.journal = append(s.journal, func() { obj.nonce = prev })
+	obj.nonce = nonce
+}
+
+// GetCodeHash implements vm.StateDB
+func (s *StateDB) GetCodeHash(address gethcommon.Address) gethcommon.Hash {
+	obj := s.getObject(address)
+	if !obj.exists {
+		return gethcommon.Hash{}
+	}
+	return obj.codeHash
+}
+
+// GetCode implements vm.StateDB
+func (s *StateDB) GetCode(address gethcommon.Address) []byte {
+	return s.getObject(address).code
+}
+
+// SetCode implements vm.StateDB
+func (s *StateDB) SetCode(address gethcommon.Address, code []byte) {
+	obj := s.getOrNewObject(address)
+	prevCode, prevHash := obj.code, obj.codeHash
+	s.journal = append(s.journal, func() { obj.code, obj.codeHash = prevCode, prevHash })
+	obj.code, obj.codeHash = code, crypto.Keccak256Hash(code)
+}
+
+// GetCodeSize implements vm.StateDB
+func (s *StateDB) GetCodeSize(address gethcommon.Address) int {
+	return len(s.getObject(address).code)
+}
+
+// AddRefund implements vm.StateDB
+func (s *StateDB) AddRefund(gas uint64) {
+	prev := s.refund
+	s.journal = append(s.journal, func() { s.refund = prev })
+	s.refund += gas
+}
+
+// SubRefund implements vm.StateDB
+func (s *StateDB) SubRefund(gas uint64) {
+	prev := s.refund
+	s.journal = append(s.journal, func() { s.refund = prev })
+	if gas > s.refund {
+		s.refund = 0
+		return
+	}
+	s.refund -= gas
+}
+
+// GetRefund implements vm.StateDB
+func (s *StateDB) GetRefund() uint64 {
+	return s.refund
+}
+
+// GetCommittedState implements vm.StateDB, it returns the slot as it was before the current message
+func (s *StateDB) GetCommittedState(address gethcommon.Address, key gethcommon.Hash) gethcommon.Hash {
+	obj := s.getObject(address)
+	if value, ok := obj.origin[key]; ok {
+		return value
+	}
+	if obj.storageWipe || !obj.exists || s.err != nil {
+		return gethcommon.Hash{}
+	}
+
+	valueHex, err := s.backend.GetStorageAt(s.ctx, address.Hex(), key.Hex(), s.height)
+	if err != nil {
+		s.setError(err)
+		return gethcommon.Hash{}
+	}
+	valueBytes, err := hex.DecodeString(strings.TrimPrefix(valueHex, "0x"))
+	if err != nil {
+		s.setError(err)
+		return gethcommon.Hash{}
+	}
+	value := gethcommon.BytesToHash(valueBytes)
+	obj.origin[key] = value
+	return value
+}
+
+// GetState implements vm.StateDB
+func (s *StateDB) GetState(address gethcommon.Address, key gethcommon.Hash) gethcommon.Hash {
+	if value, ok := s.getObject(address).dirty[key]; ok {
+		return value
+	}
+	return s.GetCommittedState(address, key)
+}
+
+// SetState implements vm.StateDB
+func (s *StateDB) SetState(address gethcommon.Address, key gethcommon.Hash, value gethcommon.Hash) {
+	obj := s.getOrNewObject(address)
+	prev, written := obj.dirty[key]
+	s.journal = append(s.journal, func() {
+		if written {
+			obj.dirty[key] = prev
+		} else {
+			delete(obj.dirty, key)
+		}
+	})
+	obj.dirty[key] = value
+}
+
+// Suicide implements vm.StateDB
+func (s *StateDB) Suicide(address gethcommon.Address) bool {
+	obj := s.getObject(address)
+	if !obj.exists {
+		return false
+	}
+	prevSuicided, prevBalance := obj.suicided, obj.balance
+	s.journal = append(s.journal, func() { obj.suicided, obj.balance = prevSuicided, prevBalance })
+	obj.suicided, obj.balance = true, new(big.Int)
+	return true
+}
+
+// HasSuicided implements vm.StateDB
+func (s *StateDB) HasSuicided(address gethcommon.Address) bool {
+	return s.getObject(address).suicided
+}
+
+// Exist implements vm.StateDB
+func (s *StateDB) Exist(address gethcommon.Address) bool {
+	return s.getObject(address).exists
+}
+
+// Empty implements vm.StateDB
+func (s *StateDB) Empty(address gethcommon.Address) bool {
+	obj := s.getObject(address)
+	return !obj.exists || (obj.nonce == 0 && obj.balance.Sign() == 0 && len(obj.code) == 0)
+}
+
+// RevertToSnapshot implements vm.StateDB
+func (s *StateDB) RevertToSnapshot(id int) {
+	for i := len(s.journal) - 1; i >= id; i-- {
+		s.journal[i]()
+	}
+	s.journal = s.journal[:id]
+}
+
+// Snapshot implements vm.StateDB
+func (s *StateDB) Snapshot() int {
+	return len(s.journal)
+}
+
+// AddLog implements vm.StateDB
+func (s *StateDB) AddLog(log *gethtypes.Log) {
+	s.journal = append(s.journal, func() { s.logs = s.logs[:len(s.logs)-1] })
+	s.logs = append(s.logs, log)
+}
+
+// AddPreimage implements vm.StateDB, the preimages are not recorded
+func (s *StateDB) AddPreimage(hash gethcommon.Hash, preimage []byte) {}
+
+// ForEachStorage implements vm.StateDB, only the slots read or written so far are visited
+func (s *StateDB) ForEachStorage(address gethcommon.Address, cb func(key, value gethcommon.Hash) bool) error {
+	obj := s.getObject(address)
+	for key, value := range obj.origin {
+		if _, ok := obj.dirty[key]; ok {
+			continue
+		}
+		if !cb(key, value) {
+			return nil
+		}
+	}
+	for key, value := range obj.dirty {
+		if !cb(key, value) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package follower
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSubscriberLagging is delivered on the Err channel of a subscription which was dropped
+// because its channel was full when a value was sent
+var ErrSubscriberLagging = errors.New("subscriber dropped, the channel was not drained in time")
+
+// feed delivers each value to all its subscribers without blocking. A subscriber whose
+// channel is full is unsubscribed and notified through its Err channel, so that a slow
+// consumer cannot stall the follower or the other subscribers.
+type feed struct {
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+// subscription implements event.Subscription
+type subscription struct {
+	feed *feed
+	send func(value interface{}) bool // non-blocking, returns false if the channel is full
+	err  chan error
+	once sync.Once
+}
+
+func (f *feed) subscribe(send func(value interface{}) bool) *subscription {
+	sub := &subscription{
+		feed: f,
+		send: send,
+		err:  make(chan error, 1),
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subs == nil {
+		f.subs = make(map[*subscription]struct{})
+	}
+	f.subs[sub] = struct{}{}
+	return sub
+}
+
+func (f *feed) send(value interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for sub := range f.subs {
+		if !sub.send(value) {
+			delete(f.subs, sub)
+			sub.close(ErrSubscriberLagging)
+		}
+	}
+}
+
+func (f *feed) remove(sub *subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subs, sub)
+}
+
+// Unsubscribe stops the delivery and closes the Err channel
+func (s *subscription) Unsubscribe() {
+	s.feed.remove(s)
+	s.close(nil)
+}
+
+// Err returns a channel which receives ErrSubscriberLagging if the subscriber was dropped,
+// it is closed once the subscription ends
+func (s *subscription) Err() <-chan error {
+	return s.err
+}
+
+func (s *subscription) close(err error) {
+	s.once.Do(func() {
+		if err != nil {
+			s.err <- err
+		}
+		close(s.err)
+	})
+}
@@ -0,0 +1,175 @@
+package follower
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "follower"})
+
+// pendingTxQueueSize bounds the transactions waiting to be relayed to the subscribers
+const pendingTxQueueSize = 1024
+
+// Follower polls the Script node for newly finalized blocks and feeds them, in height order,
+// to its subscribers. It also relays the transactions broadcast through the adaptor, so that
+// the subscribers can follow them before they are finalized.
+type Follower struct {
+	latestHeight uint64 // accessed atomically, keep it 64-bit aligned
+
+	backend       backend.ScriptBackend
+	interval      time.Duration
+	maxCatchUp    uint64
+	nextHeight    uint64
+	blockFeed     feed
+	pendingTxFeed feed
+	pendingTxs    chan tcommon.Hash
+
+	// Life cycle
+	wg     *sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewFollower creates a follower which queries the Script node through the given backend
+func NewFollower(b backend.ScriptBackend) *Follower {
+	return &Follower{
+		backend:    b,
+		interval:   time.Duration(viper.GetInt64(common.CfgFollowerPollIntervalMillis)) * time.Millisecond,
+		maxCatchUp: viper.GetUint64(common.CfgFollowerMaxCatchUpBlocks),
+		pendingTxs: make(chan tcommon.Hash, pendingTxQueueSize),
+		wg:         &sync.WaitGroup{},
+	}
+}
+
+// Start kicks off the polling loop and the pending tx relay.
+func (f *Follower) Start(ctx context.Context) {
+	c, cancel := context.WithCancel(ctx)
+	f.ctx = c
+	f.cancel = cancel
+
+	f.wg.Add(2)
+	go f.mainLoop()
+	go f.relayPendingTxs()
+}
+
+// Stop notifies the polling loop and the pending tx relay to stop without blocking.
+func (f *Follower) Stop() {
+	f.cancel()
+}
+
+// Wait blocks until the polling loop and the pending tx relay stop.
+func (f *Follower) Wait() {
+	f.wg.Wait()
+}
+
+// LatestHeight returns the height of the latest block fed to the subscribers
+func (f *Follower) LatestHeight() tcommon.JSONUint64 {
+	return tcommon.JSONUint64(atomic.LoadUint64(&f.latestHeight))
+}
+
+// SubscribeNewBlocks registers ch to receive every newly finalized block. The follower never
+// waits for a subscriber: if ch is full when a block is sent, the subscription is dropped and
+// ErrSubscriberLagging is delivered on its Err channel. ch should be buffered and drained promptly.
+func (f *Follower) SubscribeNewBlocks(ch chan<- *common.ScriptGetBlockResultInner) event.Subscription {
+	return f.blockFeed.subscribe(func(value interface{}) bool {
+		select {
+		case ch <- value.(*common.ScriptGetBlockResultInner):
+			return true
+		default:
+			return false
+		}
+	})
+}
+
+// SubscribePendingTxs registers ch to receive the hash of every transaction broadcast through
+// the adaptor, lagging subscribers are dropped as in SubscribeNewBlocks
+func (f *Follower) SubscribePendingTxs(ch chan<- tcommon.Hash) event.Subscription {
+	return f.pendingTxFeed.subscribe(func(value interface{}) bool {
+		select {
+		case ch <- value.(tcommon.Hash):
+			return true
+		default:
+			return false
+		}
+	})
+}
+
+// AddPendingTx queues the transaction to be relayed to the subscribers. It never blocks,
+// the transaction is dropped from the notifications if the queue is full.
+func (f *Follower) AddPendingTx(txHash tcommon.Hash) {
+	select {
+	case f.pendingTxs <- txHash:
+	default:
+		logger.Warnf("Pending tx queue full, not relaying tx %v", txHash.Hex())
+	}
+}
+
+func (f *Follower) relayPendingTxs() {
+	defer f.wg.Done()
+
+	for {
+		select {
+		case txHash := <-f.pendingTxs:
+			f.pendingTxFeed.send(txHash)
+		case <-f.ctx.Done():
+			return
+		}
+	}
+}
+
+func (f *Follower) mainLoop() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		f.poll()
+
+		select {
+		case <-f.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *Follower) poll() {
+	currentHeight, err := backend.GetCurrentHeight(f.ctx, f.backend)
+	if err != nil {
+		logger.Warnf("Failed to get the current height: %v", err)
+		return
+	}
+
+	height := uint64(currentHeight)
+	if f.nextHeight == 0 {
+		f.nextHeight = height // start from the current block, no need to replay the history
+	}
+	if height < f.nextHeight {
+		return
+	}
+	if f.maxCatchUp > 0 && height-f.nextHeight+1 > f.maxCatchUp {
+		logger.Warnf("Fell behind by %v blocks, skipping to height %v", height-f.nextHeight+1, height-f.maxCatchUp+1)
+		f.nextHeight = height - f.maxCatchUp + 1
+	}
+
+	for ; f.nextHeight <= height; f.nextHeight++ {
+		block, err := f.backend.GetBlockByHeight(f.ctx, tcommon.JSONUint64(f.nextHeight))
+		if err != nil {
+			logger.Warnf("Failed to get block %v: %v", f.nextHeight, err)
+			return // retry at the next tick
+		}
+
+		f.blockFeed.send(block)
+		atomic.StoreUint64(&f.latestHeight, f.nextHeight)
+	}
+}
@@ -0,0 +1,77 @@
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+)
+
+// MaxFeeHistoryBlocks caps the number of blocks of an eth_feeHistory query, as geth does
+const MaxFeeHistoryBlocks = 1024
+
+var errInvalidPercentile = errors.New("invalid reward percentile")
+
+// FeeHistory is the fee data of a range of blocks, in height order
+type FeeHistory struct {
+	OldestBlock  uint64
+	BaseFees     []*big.Int // one more entry than the blocks, the last one stands for the next block
+	GasUsedRatio []float64
+	Rewards      [][]*big.Int // nil if no percentile is requested
+}
+
+// GetFeeHistory returns the fee data of the blockCount blocks up to lastBlock
+func GetFeeHistory(ctx context.Context, b backend.ScriptBackend, retry backend.RetryPolicy, blockCount uint64, lastBlock tcommon.JSONUint64,
+	percentiles []float64, defaultGasPrice *big.Int, gasLimit uint64) (*FeeHistory, error) {
+	for i, p := range percentiles {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("%v: %f", errInvalidPercentile, p)
+		}
+		if i > 0 && p < percentiles[i-1] {
+			return nil, fmt.Errorf("%v: #%d:%f > #%d:%f", errInvalidPercentile, i-1, percentiles[i-1], i, p)
+		}
+	}
+
+	history := &FeeHistory{}
+	if blockCount == 0 {
+		return history, nil
+	}
+	if blockCount > MaxFeeHistoryBlocks {
+		blockCount = MaxFeeHistoryBlocks
+	}
+	if uint64(lastBlock)+1 < blockCount {
+		blockCount = uint64(lastBlock) + 1
+	}
+	oldestBlock := lastBlock + 1 - tcommon.JSONUint64(blockCount)
+
+	var blocks common.ScriptGetBlocksResult
+	err := retry.Do(ctx, func() (err error) {
+		blocks, err = b.GetBlocksByRange(ctx, oldestBlock, lastBlock)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, block := range blocks {
+		fees := NewBlockFees(block, defaultGasPrice, gasLimit)
+		if i == 0 {
+			history.OldestBlock = fees.Height
+		}
+		history.BaseFees = append(history.BaseFees, fees.BaseFee)
+		history.GasUsedRatio = append(history.GasUsedRatio, fees.GasUsedRatio)
+		if len(percentiles) > 0 {
+			history.Rewards = append(history.Rewards, fees.Rewards(percentiles))
+		}
+	}
+	if len(history.BaseFees) > 0 {
+		// Without a fee market, the base fee of the next block is best guessed by the latest one
+		history.BaseFees = append(history.BaseFees, history.BaseFees[len(history.BaseFees)-1])
+	}
+
+	return history, nil
+}
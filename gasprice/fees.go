@@ -0,0 +1,122 @@
+package gasprice
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script/ledger/types"
+)
+
+// Script has no EIP-1559 fee market, every smart contract transaction pays the gas price it
+// sets. To serve the EIP-1559 APIs, the base fee of a block is emulated as the lowest gas
+// price paid in the block, and the priority fee (tip) of a transaction as the part of its
+// gas price above that base fee.
+
+// DefaultGasPrice returns the gas price used when no transaction tells better
+func DefaultGasPrice(ethChainID uint64) *big.Int {
+	if ethChainID > 1000 { // must be a Subchain
+		return big.NewInt(1e8) // Default for the Subchains
+	}
+	return big.NewInt(4000000000000) // Default for the Main Chain
+}
+
+// GasPrices returns the gas prices of the smart contract transactions of the block
+func GasPrices(block *common.ScriptGetBlockResultInner) []*big.Int {
+	gasPrices := []*big.Int{}
+	for _, tx := range block.Txs {
+		if types.TxType(tx.Type) != types.TxSmartContract || tx.Tx == nil {
+			continue
+		}
+		gasPrices = append(gasPrices, tx.Tx.(*types.SmartContractTx).GasPrice)
+	}
+	return gasPrices
+}
+
+// BaseFee returns the emulated base fee of the block, or nil if the block has no smart
+// contract transaction
+func BaseFee(block *common.ScriptGetBlockResultInner) *big.Int {
+	var baseFee *big.Int
+	for _, gasPrice := range GasPrices(block) {
+		if baseFee == nil || gasPrice.Cmp(baseFee) < 0 {
+			baseFee = gasPrice
+		}
+	}
+	return baseFee
+}
+
+// Tip returns the priority fee of a transaction with the given gas price in a block with
+// the given base fee
+func Tip(gasPrice *big.Int, baseFee *big.Int) *big.Int {
+	if baseFee == nil || gasPrice.Cmp(baseFee) <= 0 {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Sub(gasPrice, baseFee)
+}
+
+type txTip struct {
+	gasUsed uint64
+	tip     *big.Int
+}
+
+// BlockFees is the fee data of a block that eth_feeHistory reports
+type BlockFees struct {
+	Height       uint64
+	BaseFee      *big.Int
+	GasUsedRatio float64
+
+	gasUsed uint64
+	tips    []txTip // sorted by tip
+}
+
+// NewBlockFees computes the fee data of the block, defaultGasPrice stands for the base fee
+// of the blocks without smart contract transactions
+func NewBlockFees(block *common.ScriptGetBlockResultInner, defaultGasPrice *big.Int, gasLimit uint64) *BlockFees {
+	fees := &BlockFees{
+		Height:  uint64(block.Height),
+		BaseFee: BaseFee(block),
+	}
+	if fees.BaseFee == nil {
+		fees.BaseFee = defaultGasPrice
+	}
+
+	for _, tx := range block.Txs {
+		if types.TxType(tx.Type) != types.TxSmartContract || tx.Tx == nil || tx.Receipt == nil {
+			continue
+		}
+		gasPrice := tx.Tx.(*types.SmartContractTx).GasPrice
+		fees.gasUsed += tx.Receipt.GasUsed
+		fees.tips = append(fees.tips, txTip{gasUsed: tx.Receipt.GasUsed, tip: Tip(gasPrice, fees.BaseFee)})
+	}
+	sort.SliceStable(fees.tips, func(i, j int) bool { return fees.tips[i].tip.Cmp(fees.tips[j].tip) < 0 })
+
+	if gasLimit > 0 {
+		fees.GasUsedRatio = float64(fees.gasUsed) / float64(gasLimit)
+	}
+	return fees
+}
+
+// Rewards returns the tips at the given percentiles, weighted by the gas used by each
+// transaction, the same way Ethereum nodes compute the rewards of eth_feeHistory. The
+// percentiles must be sorted in ascending order.
+func (f *BlockFees) Rewards(percentiles []float64) []*big.Int {
+	rewards := make([]*big.Int, len(percentiles))
+	if len(f.tips) == 0 {
+		for i := range rewards {
+			rewards[i] = big.NewInt(0)
+		}
+		return rewards
+	}
+
+	txIndex := 0
+	sumGasUsed := f.tips[0].gasUsed
+	for i, p := range percentiles {
+		thresholdGasUsed := uint64(float64(f.gasUsed) * p / 100)
+		for sumGasUsed < thresholdGasUsed && txIndex < len(f.tips)-1 {
+			txIndex++
+			sumGasUsed += f.tips[txIndex].gasUsed
+		}
+		rewards[i] = f.tips[txIndex].tip
+	}
+	return rewards
+}
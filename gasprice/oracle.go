@@ -0,0 +1,194 @@
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/follower"
+	tcommon "github.com/scripttoken/script/common"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "gasprice"})
+
+type blockSample struct {
+	height    tcommon.JSONUint64
+	gasPrices []*big.Int
+}
+
+// Oracle suggests a gas price from the gas prices paid in a sliding window of recent
+// blocks. The window is kept up to date by the follower, so that the suggestion is ready
+// when eth_gasPrice is called. The lowest and highest prices of the window are ignored as
+// outliers, the suggestion is a percentile of the remaining ones, and never goes below the
+// configured floor.
+type Oracle struct {
+	backend       backend.ScriptBackend
+	follower      *follower.Follower
+	windowBlocks  int
+	percentile    float64
+	ignorePercent float64
+	floor         *big.Int
+
+	mu       sync.RWMutex
+	window   []blockSample // in height order
+	gasPrice *big.Int      // nil until a tx is seen in the window
+
+	// Life cycle
+	wg     *sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewOracle creates an oracle fed by the blocks of the given follower
+func NewOracle(b backend.ScriptBackend, f *follower.Follower) *Oracle {
+	floor, ok := new(big.Int).SetString(viper.GetString(common.CfgGasPriceFloorWei), 0)
+	if !ok {
+		logger.Warnf("Invalid gas price floor %v, ignoring it", viper.GetString(common.CfgGasPriceFloorWei))
+		floor = big.NewInt(0)
+	}
+	windowBlocks := viper.GetInt(common.CfgGasPriceBlocks)
+	if windowBlocks < 1 {
+		windowBlocks = 1
+	}
+
+	return &Oracle{
+		backend:       b,
+		follower:      f,
+		windowBlocks:  windowBlocks,
+		percentile:    viper.GetFloat64(common.CfgGasPricePercentile),
+		ignorePercent: viper.GetFloat64(common.CfgGasPriceIgnoreOutlierPercent),
+		floor:         floor,
+		wg:            &sync.WaitGroup{},
+	}
+}
+
+// Start kicks off the loop which receives the new blocks.
+func (o *Oracle) Start(ctx context.Context) {
+	c, cancel := context.WithCancel(ctx)
+	o.ctx = c
+	o.cancel = cancel
+
+	o.wg.Add(1)
+	go o.mainLoop()
+}
+
+// Stop notifies the loop to stop without blocking.
+func (o *Oracle) Stop() {
+	o.cancel()
+}
+
+// Wait blocks until the loop stops.
+func (o *Oracle) Wait() {
+	o.wg.Wait()
+}
+
+// SuggestGasPrice returns the current suggestion. It falls back to the default gas price of
+// the chain as long as no tx has been seen in the window.
+func (o *Oracle) SuggestGasPrice(ctx context.Context) *big.Int {
+	o.mu.RLock()
+	gasPrice := o.gasPrice
+	o.mu.RUnlock()
+
+	if gasPrice == nil {
+		ethChainID, _ := backend.GetEthChainID(ctx, o.backend) // fall back to the Main Chain default on error
+		gasPrice = DefaultGasPrice(ethChainID)
+	}
+	if gasPrice.Cmp(o.floor) < 0 {
+		return o.floor
+	}
+	return gasPrice
+}
+
+func (o *Oracle) mainLoop() {
+	defer o.wg.Done()
+
+	blocks := make(chan *common.ScriptGetBlockResultInner, o.windowBlocks)
+	blocksSub := o.follower.SubscribeNewBlocks(blocks)
+	defer func() { blocksSub.Unsubscribe() }()
+
+	o.seed() // subscribed first, so that no block is missed between the seed and the feed
+
+	for {
+		select {
+		case block := <-blocks:
+			o.add([]*common.ScriptGetBlockResultInner{block})
+		case err := <-blocksSub.Err():
+			if err == nil {
+				return
+			}
+			logger.Warnf("Fell behind the follower, reseeding the window: %v", err)
+			blocksSub = o.follower.SubscribeNewBlocks(blocks)
+			o.seed()
+		case <-o.ctx.Done():
+			return
+		}
+	}
+}
+
+// seed fills the window with the latest blocks, so that the suggestion does not wait for
+// the window to fill up after a restart
+func (o *Oracle) seed() {
+	currentHeight, err := backend.GetCurrentHeight(o.ctx, o.backend)
+	if err != nil {
+		logger.Warnf("Failed to get the current height: %v", err)
+		return
+	}
+
+	start := tcommon.JSONUint64(1)
+	if uint64(currentHeight) > uint64(o.windowBlocks) {
+		start = currentHeight - tcommon.JSONUint64(o.windowBlocks) + 1
+	}
+	blocks, err := o.backend.GetBlocksByRange(o.ctx, start, currentHeight)
+	if err != nil {
+		logger.Warnf("Failed to get blocks %v to %v: %v", start, currentHeight, err)
+		return
+	}
+	o.add(blocks)
+}
+
+func (o *Oracle) add(blocks []*common.ScriptGetBlockResultInner) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, block := range blocks {
+		if len(o.window) > 0 && block.Height <= o.window[len(o.window)-1].height {
+			continue // already in the window
+		}
+		o.window = append(o.window, blockSample{height: block.Height, gasPrices: GasPrices(block)})
+	}
+	if len(o.window) > o.windowBlocks {
+		o.window = o.window[len(o.window)-o.windowBlocks:]
+	}
+
+	o.gasPrice = o.compute()
+}
+
+// compute returns the percentile of the gas prices of the window, the outliers excluded
+func (o *Oracle) compute() *big.Int {
+	gasPrices := []*big.Int{}
+	for _, sample := range o.window {
+		gasPrices = append(gasPrices, sample.gasPrices...)
+	}
+	if len(gasPrices) == 0 {
+		return nil
+	}
+	sort.Slice(gasPrices, func(i, j int) bool { return gasPrices[i].Cmp(gasPrices[j]) < 0 })
+
+	numOutliers := int(float64(len(gasPrices)) * o.ignorePercent / 100)
+	if 2*numOutliers < len(gasPrices) {
+		gasPrices = gasPrices[numOutliers : len(gasPrices)-numOutliers]
+	}
+
+	index := int(float64(len(gasPrices)-1) * o.percentile / 100)
+	if index < 0 {
+		index = 0
+	} else if index >= len(gasPrices) {
+		index = len(gasPrices) - 1
+	}
+	return gasPrices[index]
+}
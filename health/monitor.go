@@ -0,0 +1,255 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/follower"
+	"github.com/scripttoken/script-eth-rpc-adaptor/version"
+	"github.com/scripttoken/script/ledger/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "health"})
+
+// Status is a snapshot of the state of the adaptor and of the Script node it talks to, served
+// as is on the status page
+type Status struct {
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"` // why the adaptor is not ready
+
+	Version   string    `json:"version"`
+	GitHash   string    `json:"gitHash"`
+	BuildTime string    `json:"buildTime"`
+	StartTime time.Time `json:"startTime"`
+
+	ScriptChainID string `json:"scriptChainId"`
+	EthChainID    uint64 `json:"ethChainId"`
+
+	LatestFinalizedHeight    uint64    `json:"latestFinalizedHeight"`
+	LatestFinalizedBlockTime time.Time `json:"latestFinalizedBlockTime"`
+	BlockAgeSecs             int64     `json:"blockAgeSecs"`
+	FollowerHeight           uint64    `json:"followerHeight"`
+	FollowerLagBlocks        uint64    `json:"followerLagBlocks"` // behind the latest finalized block
+	Syncing                  bool      `json:"syncing"`
+
+	UpstreamLatencyMillis int64     `json:"upstreamLatencyMillis"` // of the last script.GetStatus call
+	UpstreamError         string    `json:"upstreamError,omitempty"`
+	LastCheck             time.Time `json:"lastCheck"`
+}
+
+// Monitor checks the Script node every health.checkIntervalSecs. The adaptor is ready when the
+// last check reached the node, and the latest finalized block is not older than
+// health.maxBlockAgeSecs, so that a stuck node is taken out of the load balancer.
+type Monitor struct {
+	backend     backend.ScriptBackend
+	follower    *follower.Follower
+	interval    time.Duration
+	maxBlockAge time.Duration
+	startTime   time.Time
+
+	mu     sync.RWMutex
+	status Status
+
+	// Life cycle
+	wg     *sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewMonitor creates a monitor which queries the Script node through the given backend
+func NewMonitor(b backend.ScriptBackend, f *follower.Follower) *Monitor {
+	startTime := time.Now()
+	return &Monitor{
+		backend:     b,
+		follower:    f,
+		interval:    time.Duration(viper.GetInt64(common.CfgHealthCheckIntervalSecs)) * time.Second,
+		maxBlockAge: time.Duration(viper.GetInt64(common.CfgHealthMaxBlockAgeSecs)) * time.Second,
+		startTime:   startTime,
+		status:      Status{Reason: "not checked yet", StartTime: startTime},
+		wg:          &sync.WaitGroup{},
+	}
+}
+
+// Start kicks off the check loop.
+func (m *Monitor) Start(ctx context.Context) {
+	c, cancel := context.WithCancel(ctx)
+	m.ctx = c
+	m.cancel = cancel
+
+	m.wg.Add(1)
+	go m.mainLoop()
+}
+
+// Stop notifies the check loop to stop without blocking.
+func (m *Monitor) Stop() {
+	m.cancel()
+}
+
+// Wait blocks until the check loop stops.
+func (m *Monitor) Wait() {
+	m.wg.Wait()
+}
+
+// Status returns the result of the last check. The adaptor is not ready if the checks stopped
+// running, e.g. since a call to the node hangs.
+func (m *Monitor) Status() Status {
+	m.mu.RLock()
+	status := m.status
+	m.mu.RUnlock()
+
+	status.FollowerHeight = uint64(m.follower.LatestHeight())
+	if status.LatestFinalizedHeight > status.FollowerHeight {
+		status.FollowerLagBlocks = status.LatestFinalizedHeight - status.FollowerHeight
+	}
+	if status.Ready && time.Since(status.LastCheck) > 3*m.interval {
+		status.Ready = false
+		status.Reason = fmt.Sprintf("no check completed since %v", status.LastCheck.Format(time.RFC3339))
+	}
+	return status
+}
+
+func (m *Monitor) mainLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.check()
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) check() {
+	ctx, cancel := context.WithTimeout(m.ctx, m.interval)
+	defer cancel()
+
+	status := Status{
+		Version:   version.Version,
+		GitHash:   version.GitHash,
+		BuildTime: version.Timestamp,
+		StartTime: m.startTime,
+	}
+	defer func() {
+		status.LastCheck = time.Now()
+		m.mu.Lock()
+		previous := m.status
+		m.status = status
+		m.mu.Unlock()
+
+		if status.Ready && !previous.Ready {
+			logger.Infof("Ready, latest finalized block: %v", status.LatestFinalizedHeight)
+		} else if !status.Ready && (previous.Ready || status.Reason != previous.Reason) {
+			logger.Warnf("Not ready: %v", status.Reason)
+		}
+	}()
+
+	start := time.Now()
+	scriptStatus, err := m.backend.GetStatus(ctx)
+	status.UpstreamLatencyMillis = time.Since(start).Milliseconds()
+	if err != nil {
+		status.UpstreamError = err.Error()
+		status.Reason = "the Script node is unreachable"
+		return
+	}
+	status.ScriptChainID = scriptStatus.ChainID
+	status.LatestFinalizedHeight = uint64(scriptStatus.LatestFinalizedBlockHeight)
+	status.EthChainID = types.MapChainID(scriptStatus.ChainID, status.LatestFinalizedHeight).Uint64()
+	status.Syncing = scriptStatus.Syncing
+
+	block, err := m.backend.GetBlockByHeight(ctx, scriptStatus.LatestFinalizedBlockHeight)
+	if err != nil {
+		status.UpstreamError = err.Error()
+		status.Reason = fmt.Sprintf("failed to get the latest finalized block %v", status.LatestFinalizedHeight)
+		return
+	}
+	if block.Timestamp != nil {
+		status.LatestFinalizedBlockTime = time.Unix(block.Timestamp.ToInt().Int64(), 0).UTC()
+		status.BlockAgeSecs = int64(time.Since(status.LatestFinalizedBlockTime).Seconds())
+	}
+
+	if m.maxBlockAge > 0 && time.Duration(status.BlockAgeSecs)*time.Second > m.maxBlockAge {
+		status.Reason = fmt.Sprintf("the latest finalized block %v is %vs old", status.LatestFinalizedHeight, status.BlockAgeSecs)
+		return
+	}
+	status.Ready = true
+}
+
+// ExportMetrics registers the readiness and the age of the latest finalized block as metrics, it
+// must be called once at most
+func (m *Monitor) ExportMetrics() {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "adaptor_ready",
+		Help: "1 if the adaptor is ready to serve, 0 otherwise.",
+	}, func() float64 {
+		if m.Status().Ready {
+			return 1
+		}
+		return 0
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "adaptor_upstream_block_age_seconds",
+		Help: "Age of the latest finalized block of the Script node.",
+	}, func() float64 {
+		return float64(m.Status().BlockAgeSecs)
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "adaptor_follower_lag_blocks",
+		Help: "Finalized blocks not yet fed to the subscriptions.",
+	}, func() float64 {
+		return float64(m.Status().FollowerLagBlocks)
+	})
+}
+
+// Handler serves /health, which answers as long as the process is up, /ready, which answers 503
+// when the adaptor is not ready, and /status, the status page. The other requests go to next.
+func (m *Monitor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch r.URL.Path {
+		case "/health":
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		case "/ready":
+			status := m.Status()
+			code := http.StatusOK
+			if !status.Ready {
+				code = http.StatusServiceUnavailable
+			}
+			writeJSON(w, code, map[string]interface{}{"ready": status.Ready, "reason": status.Reason})
+		case "/status":
+			writeJSON(w, http.StatusOK, m.Status())
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	w.Write(body)
+}
@@ -0,0 +1,278 @@
+package logindex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sync/atomic"
+
+	tcommon "github.com/scripttoken/script/common"
+	log "github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "logindex"})
+
+// Key layout. A log is identified by its position, i.e. the big-endian encoding of its
+// block height, tx index and log index, so that the keys of a prefix sort in chain order.
+//
+//	m:start                                  -> first indexed height
+//	m:last                                   -> last indexed height
+//	l:<position>                             -> JSON encoded Log
+//	a:<address><position>                    -> nil
+//	t:<topic index><topic><position>         -> nil
+var (
+	startHeightKey = []byte("m:start")
+	lastHeightKey  = []byte("m:last")
+	logPrefix      = []byte("l:")
+	addressPrefix  = []byte("a:")
+	topicPrefix    = []byte("t:")
+)
+
+const positionLen = 8 + 4 + 4
+
+// Log is a log of a finalized smart contract transaction, as stored in the index
+type Log struct {
+	Address     tcommon.Address    `json:"address"`
+	Topics      []tcommon.Hash     `json:"topics"`
+	Data        []byte             `json:"data"`
+	BlockHash   tcommon.Hash       `json:"blockHash"`
+	BlockHeight tcommon.JSONUint64 `json:"blockHeight"`
+	TxHash      tcommon.Hash       `json:"txHash"`
+	TxIndex     int                `json:"txIndex"`
+	LogIndex    int                `json:"logIndex"` // index of the log in the receipt of the tx
+}
+
+// Index stores the logs of the finalized blocks in a LevelDB database, indexed by contract
+// address and by topic, so that the logs of a large block range can be looked up without
+// scanning every block. The indexed heights are contiguous, from StartHeight to LastHeight.
+type Index struct {
+	startHeight uint64 // accessed atomically, keep it 64-bit aligned
+	lastHeight  uint64 // accessed atomically
+
+	db *leveldb.DB
+}
+
+// Open opens or creates the index stored at the given path
+func Open(path string) (*Index, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Index{db: db}
+	if val, err := db.Get(startHeightKey, nil); err == nil {
+		idx.startHeight = binary.BigEndian.Uint64(val)
+	}
+	if val, err := db.Get(lastHeightKey, nil); err == nil {
+		idx.lastHeight = binary.BigEndian.Uint64(val)
+	}
+	return idx, nil
+}
+
+// Close closes the underlying database
+func (idx *Index) Close() error {
+	return idx.db.Close()
+}
+
+// IsEmpty tells whether no block has been indexed yet
+func (idx *Index) IsEmpty() bool {
+	return idx.LastHeight() == 0
+}
+
+// StartHeight returns the first indexed height
+func (idx *Index) StartHeight() tcommon.JSONUint64 {
+	return tcommon.JSONUint64(atomic.LoadUint64(&idx.startHeight))
+}
+
+// LastHeight returns the last indexed height, or 0 if nothing has been indexed yet
+func (idx *Index) LastHeight() tcommon.JSONUint64 {
+	return tcommon.JSONUint64(atomic.LoadUint64(&idx.lastHeight))
+}
+
+// Covers tells whether all the blocks in [start, end] are indexed
+func (idx *Index) Covers(start tcommon.JSONUint64, end tcommon.JSONUint64) bool {
+	return !idx.IsEmpty() && start >= idx.StartHeight() && end <= idx.LastHeight()
+}
+
+// Write indexes the logs of the blocks in [start, end], where start must directly follow
+// the last indexed height. The logs and the new last height are written atomically, so the
+// index stays consistent if the process crashes midway.
+func (idx *Index) Write(start tcommon.JSONUint64, end tcommon.JSONUint64, logs []*Log) error {
+	batch := new(leveldb.Batch)
+	for _, l := range logs {
+		pos := position(l.BlockHeight, l.TxIndex, l.LogIndex)
+		val, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		batch.Put(concat(logPrefix, pos), val)
+		batch.Put(concat(addressPrefix, l.Address[:], pos), nil)
+		for i, topic := range l.Topics {
+			batch.Put(concat(topicPrefix, []byte{byte(i)}, topic[:], pos), nil)
+		}
+	}
+
+	isEmpty := idx.IsEmpty()
+	if isEmpty {
+		batch.Put(startHeightKey, encodeUint64(uint64(start)))
+	}
+	batch.Put(lastHeightKey, encodeUint64(uint64(end)))
+
+	if err := idx.db.Write(batch, nil); err != nil {
+		return err
+	}
+	if isEmpty {
+		atomic.StoreUint64(&idx.startHeight, uint64(start))
+	}
+	atomic.StoreUint64(&idx.lastHeight, uint64(end))
+	return nil
+}
+
+// Query visits, in chain order, the candidate logs of the blocks in [start, end] for the
+// given addresses and topics. The candidates are looked up by address if any, then by the
+// first constrained topic position, and otherwise all the logs of the range are visited;
+// the visitor is expected to apply the complete filter to the candidates. The logs are read
+// one at a time, and the query stops at the first error returned by the visitor.
+func (idx *Index) Query(start tcommon.JSONUint64, end tcommon.JSONUint64, addresses []tcommon.Address, topicsFilter [][]tcommon.Hash, visit func(l *Log) error) error {
+	startPos := encodeUint64(uint64(start))
+	endPos := encodeUint64(uint64(end) + 1)
+
+	var prefixes [][]byte
+	if len(addresses) > 0 {
+		for _, address := range addresses {
+			prefixes = append(prefixes, concat(addressPrefix, address[:]))
+		}
+	} else {
+		for i, topics := range topicsFilter {
+			if !isConstrained(topics) {
+				continue
+			}
+			for _, topic := range topics {
+				prefixes = append(prefixes, concat(topicPrefix, []byte{byte(i)}, topic[:]))
+			}
+			break
+		}
+	}
+
+	if len(prefixes) == 0 {
+		return idx.scan(startPos, endPos, visit)
+	}
+
+	// The keys of each prefix sort in chain order, so the candidates are visited by merging the
+	// cursors of the prefixes rather than by collecting the positions of the whole range
+	cursors := make([]*cursor, 0, len(prefixes))
+	defer func() {
+		for _, c := range cursors {
+			c.iter.Release()
+		}
+	}()
+	for _, prefix := range prefixes {
+		c := &cursor{
+			iter:   idx.db.NewIterator(&util.Range{Start: concat(prefix, startPos), Limit: concat(prefix, endPos)}, nil),
+			prefix: prefix,
+		}
+		cursors = append(cursors, c)
+		if err := c.next(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var pos []byte
+		for _, c := range cursors {
+			if c.pos != nil && (pos == nil || bytes.Compare(c.pos, pos) < 0) {
+				pos = c.pos
+			}
+		}
+		if pos == nil {
+			return nil
+		}
+
+		val, err := idx.db.Get(concat(logPrefix, pos), nil)
+		if err != nil {
+			return err
+		}
+		l := &Log{}
+		if err := json.Unmarshal(val, l); err != nil {
+			return err
+		}
+		if err := visit(l); err != nil {
+			return err
+		}
+
+		// A log matching several prefixes is visited once
+		for _, c := range cursors {
+			if c.pos != nil && bytes.Equal(c.pos, pos) {
+				if err := c.next(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// cursor iterates over the positions of the keys of a prefix, in chain order
+type cursor struct {
+	iter   iterator.Iterator
+	prefix []byte
+	pos    []byte // nil once the iterator is exhausted
+}
+
+func (c *cursor) next() error {
+	if !c.iter.Next() {
+		c.pos = nil
+		return c.iter.Error()
+	}
+	c.pos = append([]byte{}, c.iter.Key()[len(c.prefix):]...)
+	return nil
+}
+
+func (idx *Index) scan(startPos []byte, endPos []byte, visit func(l *Log) error) error {
+	iter := idx.db.NewIterator(&util.Range{Start: concat(logPrefix, startPos), Limit: concat(logPrefix, endPos)}, nil)
+	defer iter.Release()
+	for iter.Next() {
+		l := &Log{}
+		if err := json.Unmarshal(iter.Value(), l); err != nil {
+			return err
+		}
+		if err := visit(l); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// isConstrained tells whether a topic position of the filter rules out some logs, an empty
+// list or a zero hash matches any topic
+func isConstrained(topics []tcommon.Hash) bool {
+	if len(topics) == 0 {
+		return false
+	}
+	for _, topic := range topics {
+		if (topic == tcommon.Hash{}) {
+			return false
+		}
+	}
+	return true
+}
+
+func position(height tcommon.JSONUint64, txIndex int, logIndex int) []byte {
+	pos := make([]byte, positionLen)
+	binary.BigEndian.PutUint64(pos[0:8], uint64(height))
+	binary.BigEndian.PutUint32(pos[8:12], uint32(txIndex))
+	binary.BigEndian.PutUint32(pos[12:16], uint32(logIndex))
+	return pos
+}
+
+func encodeUint64(val uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, val)
+	return buf
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
@@ -0,0 +1,84 @@
+package logindex
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	tcommon "github.com/scripttoken/script/common"
+)
+
+func TestQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	idx, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+
+	addrA := tcommon.HexToAddress("0xa")
+	addrB := tcommon.HexToAddress("0xb")
+	addrC := tcommon.HexToAddress("0xc")
+	topic := tcommon.HexToHash("0x01")
+	logs := []*Log{
+		{Address: addrA, BlockHeight: 1, TxIndex: 0, LogIndex: 0, Topics: []tcommon.Hash{topic}},
+		{Address: addrB, BlockHeight: 1, TxIndex: 1, LogIndex: 0},
+		{Address: addrC, BlockHeight: 2, TxIndex: 0, LogIndex: 0, Topics: []tcommon.Hash{topic}},
+		{Address: addrB, BlockHeight: 3, TxIndex: 0, LogIndex: 0, Topics: []tcommon.Hash{topic}},
+		{Address: addrA, BlockHeight: 3, TxIndex: 0, LogIndex: 1},
+		{Address: addrA, BlockHeight: 5, TxIndex: 2, LogIndex: 0},
+	}
+	if err := idx.Write(1, 5, logs); err != nil {
+		t.Fatal(err)
+	}
+
+	errStop := errors.New("stop")
+	tests := []struct {
+		name         string
+		start, end   tcommon.JSONUint64
+		addresses    []tcommon.Address
+		topicsFilter [][]tcommon.Hash
+		limit        int // the visitor stops the query after limit logs, if positive
+		want         []int
+	}{
+		{"all", 1, 5, nil, nil, 0, []int{0, 1, 2, 3, 4, 5}},
+		{"range", 2, 3, nil, nil, 0, []int{2, 3, 4}},
+		{"addresses merged in chain order", 1, 5, []tcommon.Address{addrA, addrB}, nil, 0, []int{0, 1, 3, 4, 5}},
+		{"duplicate address", 1, 5, []tcommon.Address{addrB, addrB}, nil, 0, []int{1, 3}},
+		{"topic", 1, 5, nil, [][]tcommon.Hash{{topic}}, 0, []int{0, 2, 3}},
+		{"stopped by the visitor", 1, 5, []tcommon.Address{addrA, addrB}, nil, 2, []int{0, 1}},
+	}
+
+	for _, test := range tests {
+		got := []int{}
+		err := idx.Query(test.start, test.end, test.addresses, test.topicsFilter, func(l *Log) error {
+			if test.limit > 0 && len(got) >= test.limit {
+				return errStop
+			}
+			for i, want := range logs {
+				if l.BlockHeight == want.BlockHeight && l.TxIndex == want.TxIndex && l.LogIndex == want.LogIndex {
+					got = append(got, i)
+				}
+			}
+			return nil
+		})
+		if (err != nil) != (test.limit > 0) {
+			t.Errorf("%v: Query() error = %v", test.name, err)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%v: Query() visited %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%v: Query() visited %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package logindex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+	"github.com/spf13/viper"
+)
+
+// Indexer fills the index with the logs of the finalized blocks in the background. It
+// resumes from the last indexed height after a restart, and backfills from the configured
+// start height on the first run.
+type Indexer struct {
+	index       *Index
+	backend     backend.ScriptBackend
+	retry       backend.RetryPolicy
+	interval    time.Duration
+	batchBlocks tcommon.JSONUint64
+	startHeight tcommon.JSONUint64
+
+	// Life cycle
+	wg     *sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewIndexer creates an indexer which writes the logs queried through b into idx
+func NewIndexer(idx *Index, b backend.ScriptBackend) *Indexer {
+	batchBlocks := viper.GetUint64(common.CfgLogIndexBatchBlocks)
+	if batchBlocks == 0 {
+		batchBlocks = 1
+	}
+	return &Indexer{
+		index:       idx,
+		backend:     b,
+		retry:       backend.RetryPolicyFromConfig(),
+		interval:    time.Duration(viper.GetInt64(common.CfgFollowerPollIntervalMillis)) * time.Millisecond,
+		batchBlocks: tcommon.JSONUint64(batchBlocks),
+		startHeight: tcommon.JSONUint64(viper.GetUint64(common.CfgLogIndexStartHeight)),
+		wg:          &sync.WaitGroup{},
+	}
+}
+
+// Start kicks off the indexing loop.
+func (ix *Indexer) Start(ctx context.Context) {
+	c, cancel := context.WithCancel(ctx)
+	ix.ctx = c
+	ix.cancel = cancel
+
+	ix.wg.Add(1)
+	go ix.mainLoop()
+}
+
+// Stop notifies the indexing loop to stop without blocking.
+func (ix *Indexer) Stop() {
+	ix.cancel()
+}
+
+// Wait blocks until the indexing loop stops.
+func (ix *Indexer) Wait() {
+	ix.wg.Wait()
+}
+
+func (ix *Indexer) mainLoop() {
+	defer ix.wg.Done()
+
+	ticker := time.NewTicker(ix.interval)
+	defer ticker.Stop()
+
+	for {
+		ix.catchUp()
+
+		select {
+		case <-ix.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// catchUp indexes the blocks finalized since the last indexed height, batch by batch
+func (ix *Indexer) catchUp() {
+	currentHeight, err := backend.GetCurrentHeight(ix.ctx, ix.backend)
+	if err != nil {
+		logger.Warnf("Failed to get the current height: %v", err)
+		return
+	}
+
+	next := ix.index.LastHeight() + 1
+	if ix.index.IsEmpty() {
+		next = ix.startHeight
+		if next == 0 {
+			next = 1
+		}
+	}
+
+	for next <= currentHeight && ix.ctx.Err() == nil {
+		end := next + ix.batchBlocks - 1
+		if end > currentHeight {
+			end = currentHeight
+		}
+
+		var blocks common.ScriptGetBlocksResult
+		err := ix.retry.Do(ix.ctx, func() (err error) {
+			blocks, err = ix.backend.GetBlocksByRange(ix.ctx, next, end)
+			return err
+		})
+		if err != nil {
+			logger.Warnf("Failed to get blocks %v to %v: %v", next, end, err)
+			return // retry at the next tick
+		}
+		if tcommon.JSONUint64(len(blocks)) != end-next+1 {
+			logger.Warnf("Got %v blocks for heights %v to %v, retrying at the next tick", len(blocks), next, end)
+			return
+		}
+
+		if err := ix.index.Write(next, end, extractLogs(blocks)); err != nil {
+			logger.Errorf("Failed to index blocks %v to %v: %v", next, end, err)
+			return
+		}
+		logger.Debugf("Indexed blocks %v to %v", next, end)
+
+		next = end + 1
+	}
+}
+
+func extractLogs(blocks common.ScriptGetBlocksResult) []*Log {
+	logs := []*Log{}
+	for _, block := range blocks {
+		for txIndex, tx := range block.Txs {
+			if types.TxType(tx.Type) != types.TxSmartContract || tx.Receipt == nil {
+				continue
+			}
+			for logIndex, log := range tx.Receipt.Logs {
+				logs = append(logs, &Log{
+					Address:     log.Address,
+					Topics:      log.Topics,
+					Data:        log.Data,
+					BlockHash:   block.Hash,
+					BlockHeight: block.Height,
+					TxHash:      tx.Hash,
+					TxIndex:     txIndex,
+					LogIndex:    logIndex,
+				})
+			}
+		}
+	}
+	return logs
+}
@@ -2,16 +2,44 @@ package node
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path"
 	"sync"
+	"time"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/apikey"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/cache"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/follower"
+	"github.com/scripttoken/script-eth-rpc-adaptor/gasprice"
+	"github.com/scripttoken/script-eth-rpc-adaptor/health"
+	"github.com/scripttoken/script-eth-rpc-adaptor/logindex"
 	"github.com/scripttoken/script-eth-rpc-adaptor/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/tracing"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	erpclib "github.com/ethereum/go-ethereum/rpc"
 )
 
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "node"})
+
+// cacheStatsInterval is how often the counters of the caches and of the API keys are logged
+const cacheStatsInterval = time.Minute
+
 type Node struct {
+	backend  backend.ScriptBackend
+	follower *follower.Follower
+	oracle   *gasprice.Oracle
+	monitor  *health.Monitor
+	logIndex *logindex.Index   // nil if the log index is disabled
+	indexer  *logindex.Indexer // nil if the log index is disabled
+	apiKeys  *apikey.Store     // nil if the API keys are disabled
+	tracer   *tracing.Tracer   // nil if tracing is disabled
+
 	// Life cycle
 	wg      *sync.WaitGroup
 	quit    chan struct{}
@@ -21,21 +49,103 @@ type Node struct {
 }
 
 func NewNode() *Node {
+	client := backend.NewScriptClient(common.GetScriptRPCEndpoint())
+	b := backend.NewCachingBackend(client, viper.GetInt64(common.CfgCacheBlocksMB)<<20)
+	f := follower.NewFollower(b)
 	node := &Node{
-		wg: &sync.WaitGroup{},
+		backend:  b,
+		follower: f,
+		oracle:   gasprice.NewOracle(b, f),
+		monitor:  health.NewMonitor(b, f),
+		wg:       &sync.WaitGroup{},
+	}
+
+	if viper.GetBool(common.CfgLogIndexEnabled) {
+		dbPath := viper.GetString(common.CfgLogIndexDBPath)
+		if dbPath == "" {
+			dbPath = path.Join(viper.GetString(common.CfgConfigPath), "db", "logindex")
+		}
+		idx, err := logindex.Open(dbPath)
+		if err != nil {
+			logger.Fatalf("Failed to open the log index at %v: %v", dbPath, err)
+		}
+		node.logIndex = idx
+		node.indexer = logindex.NewIndexer(idx, client) // bypass the block cache when backfilling
+	}
+
+	if viper.GetBool(common.CfgAPIKeysEnabled) {
+		keys := []apikey.Key{}
+		if err := viper.UnmarshalKey(common.CfgAPIKeysKeys, &keys); err != nil {
+			logger.Fatalf("Invalid %v: %v", common.CfgAPIKeysKeys, err)
+		}
+		reloadInterval := time.Duration(viper.GetInt64(common.CfgAPIKeysReloadIntervalSecs)) * time.Second
+		store, err := apikey.NewStore(keys, viper.GetString(common.CfgAPIKeysFile), reloadInterval)
+		if err != nil {
+			logger.Fatalf("Failed to load the API keys: %v", err)
+		}
+		store.ExportMetrics()
+		node.apiKeys = store
 	}
 
+	if viper.GetBool(common.CfgTracingEnabled) {
+		exporter, err := newTraceExporter()
+		if err != nil {
+			logger.Fatalf("Failed to create the trace exporter: %v", err)
+		}
+		node.tracer = tracing.NewTracer(exporter, viper.GetString(common.CfgTracingServiceName),
+			viper.GetFloat64(common.CfgTracingSampleRatio))
+	}
+
+	node.monitor.ExportMetrics()
+
 	return node
 }
 
+// newTraceExporter creates the exporter set by tracing.exporter
+func newTraceExporter() (sdktrace.SpanExporter, error) {
+	switch exporter := viper.GetString(common.CfgTracingExporter); exporter {
+	case "otlp":
+		return tracing.NewOTLPExporter(viper.GetString(common.CfgTracingOTLPEndpoint),
+			viper.GetStringMapString(common.CfgTracingOTLPHeaders))
+	case "stdout":
+		return tracing.NewWriterExporter(os.Stdout)
+	case "file":
+		filePath := viper.GetString(common.CfgTracingFile)
+		if filePath == "" {
+			filePath = path.Join(viper.GetString(common.CfgConfigPath), "traces.jsonl")
+		}
+		file, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return tracing.NewWriterExporter(file)
+	default:
+		return nil, fmt.Errorf("unknown %v %q, expecting otlp, stdout or file", common.CfgTracingExporter, exporter)
+	}
+}
+
 // Start starts sub components and kick off the main loop.
 func (n *Node) Start(ctx context.Context) {
 	c, cancel := context.WithCancel(ctx)
 	n.ctx = c
 	n.cancel = cancel
 
-	if viper.GetBool(common.CfgRPCEnabled) {
-		rpc.StartServers([]erpclib.API{})
+	if n.tracer != nil {
+		n.tracer.Start()
+	}
+
+	n.follower.Start(n.ctx)
+	n.oracle.Start(n.ctx)
+	n.monitor.Start(n.ctx)
+	if n.indexer != nil {
+		n.indexer.Start(n.ctx)
+	}
+	if n.apiKeys != nil {
+		n.apiKeys.Start(n.ctx)
+	}
+
+	if err := rpc.StartServers(n.backend, n.follower, n.logIndex, n.oracle, n.apiKeys, n.monitor, []erpclib.API{}); err != nil {
+		logger.Fatalf("Failed to start the RPC servers: %v", err)
 	}
 
 	n.wg.Add(1)
@@ -46,17 +156,61 @@ func (n *Node) Start(ctx context.Context) {
 func (n *Node) Stop() {
 	n.cancel()
 
+	n.follower.Stop()
+	n.oracle.Stop()
+	n.monitor.Stop()
+	if n.indexer != nil {
+		n.indexer.Stop()
+	}
+	if n.apiKeys != nil {
+		n.apiKeys.Stop()
+	}
 	rpc.StopServers()
+	if n.tracer != nil {
+		n.tracer.Stop() // after the servers, so that the spans of the drained calls are exported
+	}
 }
 
 // Wait blocks until all sub components stop.
 func (n *Node) Wait() {
+	n.follower.Wait()
+	n.oracle.Wait()
+	n.monitor.Wait()
+	if n.indexer != nil {
+		n.indexer.Wait()
+		n.logIndex.Close()
+	}
+	if n.apiKeys != nil {
+		n.apiKeys.Wait()
+	}
+	if n.tracer != nil {
+		n.tracer.Wait()
+	}
 	n.wg.Wait()
 }
 
 func (n *Node) mainLoop() {
 	defer n.wg.Done()
 
-	<-n.ctx.Done()
-	n.stopped = true
+	ticker := time.NewTicker(cacheStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			n.stopped = true
+			return
+		case <-ticker.C:
+			for _, stats := range cache.AllStats() {
+				logger.Debugf("Cache %v, hits: %v, misses: %v, evictions: %v, entries: %v, bytes: %v/%v",
+					stats.Name, stats.Hits, stats.Misses, stats.Evictions, stats.Entries, stats.Bytes, stats.MaxBytes)
+			}
+			if n.apiKeys != nil {
+				for _, usage := range n.apiKeys.Usage() {
+					logger.Debugf("API key %v, requests: %v, compute units: %v, rejected: %v",
+						usage.Name, usage.Requests, usage.ComputeUnits, usage.Rejected)
+				}
+			}
+		}
+	}
 }
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval sets how often the buckets which have refilled are dropped
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  float64
+}
+
+// Limiter keeps a token bucket per key, such as a client IP. Each bucket holds up to burst
+// tokens and refills at rate tokens per second. It is safe for concurrent use. The buckets
+// which have refilled are dropped, so that the memory used is bounded by the number of keys
+// active over the last few seconds.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter creates a limiter, a non-positive rate disables it
+func NewLimiter(rate float64, burst float64) *Limiter {
+	if burst < 1 {
+		burst = math.Max(rate, 1)
+	}
+	return &Limiter{
+		rate:      rate,
+		burst:     burst,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take takes cost tokens from the bucket of the key. If the bucket does not hold enough
+// tokens, none are taken and Take returns how long until it will. A cost larger than the
+// burst is capped to the burst, so that it can be served once the bucket is full.
+func (l *Limiter) Take(key string, cost float64) (ok bool, retryAfter time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	return l.TakeWithLimit(key, cost, l.rate, l.burst)
+}
+
+// TakeWithLimit is Take for a key with its own rate and burst, instead of the ones of the limiter
+func (l *Limiter) TakeWithLimit(key string, cost float64, rate float64, burst float64) (ok bool, retryAfter time.Duration) {
+	if l == nil || rate <= 0 {
+		return true, 0
+	}
+	if burst < 1 {
+		burst = math.Max(rate, 1)
+	}
+	cost = math.Min(cost, burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	b.rate = rate
+	b.burst = burst
+
+	if b.tokens < cost {
+		missing := cost - b.tokens
+		return false, time.Duration(missing / rate * float64(time.Second))
+	}
+	b.tokens -= cost
+	return true, 0
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterTake(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst float64
+		costs []float64
+		want  []bool
+	}{
+		{"within burst", 1, 3, []float64{1, 1, 1}, []bool{true, true, true}},
+		{"burst exhausted", 1, 2, []float64{1, 1, 1}, []bool{true, true, false}},
+		{"cost above tokens left", 1, 3, []float64{2, 2}, []bool{true, false}},
+		{"cost capped to burst", 1, 2, []float64{5, 1}, []bool{true, false}},
+		{"burst defaults to rate", 2, 0, []float64{1, 1, 1}, []bool{true, true, false}},
+		{"disabled", 0, 0, []float64{100, 100}, []bool{true, true}},
+	}
+
+	for _, test := range tests {
+		l := NewLimiter(test.rate, test.burst)
+		for i, cost := range test.costs {
+			ok, retryAfter := l.Take("key", cost)
+			if ok != test.want[i] {
+				t.Errorf("%v: call %v, Take() = %v, want %v", test.name, i, ok, test.want[i])
+			}
+			if !ok && retryAfter <= 0 {
+				t.Errorf("%v: call %v, retryAfter = %v, want a positive wait", test.name, i, retryAfter)
+			}
+		}
+	}
+}
+
+func TestLimiterKeys(t *testing.T) {
+	l := NewLimiter(1, 1)
+	if ok, _ := l.Take("a", 1); !ok {
+		t.Fatalf("first call of a rejected")
+	}
+	if ok, _ := l.Take("a", 1); ok {
+		t.Errorf("second call of a accepted")
+	}
+	if ok, _ := l.Take("b", 1); !ok {
+		t.Errorf("first call of b rejected, the keys should not share a bucket")
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := NewLimiter(100, 1)
+	if ok, _ := l.Take("key", 1); !ok {
+		t.Fatalf("first call rejected")
+	}
+	ok, retryAfter := l.Take("key", 1)
+	if ok {
+		t.Fatalf("second call accepted")
+	}
+	time.Sleep(retryAfter + 5*time.Millisecond)
+	if ok, _ := l.Take("key", 1); !ok {
+		t.Errorf("call rejected after waiting for %v", retryAfter)
+	}
+}
+
+func TestLimiterTakeWithLimit(t *testing.T) {
+	l := NewLimiter(0, 0) // disabled for the keys without their own limit
+	if ok, _ := l.TakeWithLimit("key", 1, 1, 1); !ok {
+		t.Fatalf("first call rejected")
+	}
+	if ok, _ := l.TakeWithLimit("key", 1, 1, 1); ok {
+		t.Errorf("second call accepted, the limit of the key should apply")
+	}
+	if ok, _ := l.Take("key", 1); !ok {
+		t.Errorf("call without a limit rejected")
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	if ok, _ := l.Take("key", 1); !ok {
+		t.Errorf("nil limiter rejected a call")
+	}
+}
@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/apikey"
+)
+
+const (
+	errCodeUnauthorized = -32001
+
+	// apiKeyPathPrefix is the request path carrying the API key, as in /rpc/<key>
+	apiKeyPathPrefix = "/rpc/"
+)
+
+// requestAPIKey returns the API key of the request, passed in the X-API-Key header or in the path
+func requestAPIKey(r *http.Request) string {
+	if apiKey := r.Header.Get(apiKeyHeader); apiKey != "" {
+		return apiKey
+	}
+	if strings.HasPrefix(r.URL.Path, apiKeyPathPrefix) {
+		return strings.Trim(strings.TrimPrefix(r.URL.Path, apiKeyPathPrefix), "/")
+	}
+	return ""
+}
+
+// apiKeyGate returns a callGate rejecting the calls with an unknown API key, or to a namespace not
+// granted to their key. If required is set, the calls without an API key are rejected as well.
+func apiKeyGate(keys *apikey.Store, required bool) callGate {
+	if keys == nil {
+		return nil
+	}
+	return func(r *http.Request, msg json.RawMessage) (json.RawMessage, bool) {
+		obj := messageHeader(msg)
+		apiKey := requestAPIKey(r)
+		if apiKey == "" {
+			if required {
+				return errorResponse(obj.ID, errCodeUnauthorized, "API key required"), true
+			}
+			return nil, false
+		}
+
+		k, found := keys.Lookup(apiKey)
+		if !found {
+			return errorResponse(obj.ID, errCodeUnauthorized, "invalid API key"), true
+		}
+		if !k.AllowsMethod(obj.Method) {
+			keys.RecordRejected(k.Name)
+			return errorResponse(obj.ID, errCodeMethodNotFound, fmt.Sprintf("the method %v is not available to this API key", obj.Method)), true
+		}
+		return nil, false
+	}
+}
+
+// usageGate returns a callGate counting the calls served for each API key, it never rejects a call
+func usageGate(keys *apikey.Store, costs methodCosts) callGate {
+	if keys == nil {
+		return nil
+	}
+	return func(r *http.Request, msg json.RawMessage) (json.RawMessage, bool) {
+		if k, found := keys.Lookup(requestAPIKey(r)); found {
+			keys.Record(k.Name, uint64(costs.cost(messageHeader(msg).Method)))
+		}
+		return nil, false
+	}
+}
@@ -0,0 +1,238 @@
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/spf13/viper"
+)
+
+const (
+	jwtSecretLength = 32
+
+	// jwtMaxClockSkew bounds the difference between the iat claim of a token and the local clock,
+	// the same bound as the Engine API
+	jwtMaxClockSkew = 60 * time.Second
+)
+
+var (
+	authServer      *http.Server
+	authHandler     *erpclib.Server
+	authEndpoint    = ""
+	authModules     = []string{}
+	ipcListener     net.Listener
+	ipcHandler      *erpclib.Server
+	errMissingToken = errors.New("missing token")
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type jwtClaims struct {
+	Iat *int64 `json:"iat"`
+}
+
+// startAuth starts the authenticated http & ws server, and the IPC endpoint if one is configured.
+// Both serve the authrpc.modules, the private methods included.
+func startAuth(apis []erpclib.API) (err error) {
+	if authModules, err = parseModules(common.CfgAuthRPCModules); err != nil {
+		return err
+	}
+
+	if ipcPath := viper.GetString(common.CfgAuthRPCIPCPath); ipcPath != "" {
+		ipcListener, ipcHandler, err = erpclib.StartIPCEndpoint(ipcPath, modulesAPIs(apis, authModules))
+		if err != nil {
+			return err
+		}
+		logger.Infof("Started IPC endpoint at: %v\n", ipcPath)
+	}
+
+	if !viper.GetBool(common.CfgAuthRPCEnabled) {
+		return nil
+	}
+
+	secretFile := viper.GetString(common.CfgAuthRPCJWTSecretFile)
+	if secretFile == "" {
+		secretFile = path.Join(viper.GetString(common.CfgConfigPath), "jwtsecret")
+	}
+	secret, err := loadJWTSecret(secretFile)
+	if err != nil {
+		return err
+	}
+
+	authHandler, err = newRPCServer(apis, authModules)
+	if err != nil {
+		return err
+	}
+	authEndpoint = fmt.Sprintf("%v:%v", viper.GetString(common.CfgAuthRPCAddress), viper.GetString(common.CfgAuthRPCPort))
+	listener, err := net.Listen("tcp", authEndpoint)
+	if err != nil {
+		return err
+	}
+
+	limits := newCallLimits()
+	httpStack := newTimeoutHandler(newHTTPLimitHandler(authHandler, limits, nil), requestTimeout)
+	wsStack := newWSHandler(authHandler, []string{"*"}, limits, nil, wsPingInterval)
+	authServer = &http.Server{
+		Handler:      newJWTHandler(secret, httpStack, wsStack),
+		ReadTimeout:  httpTimeouts.ReadTimeout,
+		WriteTimeout: httpTimeouts.WriteTimeout,
+		IdleTimeout:  httpTimeouts.IdleTimeout,
+	}
+	go authServer.Serve(newLimitListener(listener, connSlots))
+
+	logger.Infof("Started authenticated RPC server at: %v, modules: %v\n", authEndpoint, authModules)
+	return nil
+}
+
+// modulesAPIs returns the APIs of the given modules
+func modulesAPIs(apis []erpclib.API, modules []string) []erpclib.API {
+	whitelist := make(map[string]bool)
+	for _, module := range modules {
+		whitelist[module] = true
+	}
+	result := []erpclib.API{}
+	for _, api := range apis {
+		if whitelist[api.Namespace] {
+			result = append(result, api)
+		}
+	}
+	return result
+}
+
+// newJWTHandler serves the requests bearing a valid token, over http or websocket
+func newJWTHandler(secret []byte, httpStack, wsStack http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := verifyJWT(secret, r.Header.Get("Authorization")); err != nil {
+			logger.Debugf("Rejected an unauthenticated request from %v: %v", r.RemoteAddr, err)
+			http.Error(w, "invalid token: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			wsStack.ServeHTTP(w, r)
+			return
+		}
+		httpStack.ServeHTTP(w, r)
+	})
+}
+
+// verifyJWT checks the "Bearer <token>" authorization: the token must be signed with HS256 and
+// the given secret, and issued within jwtMaxClockSkew of the local time
+func verifyJWT(secret []byte, authorization string) error {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return errMissingToken
+	}
+	parts := strings.Split(strings.TrimPrefix(authorization, "Bearer "), ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	header := jwtHeader{}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "HS256" {
+		return fmt.Errorf("unsupported signing algorithm %v", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("signature mismatch")
+	}
+
+	claims := jwtClaims{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return err
+	}
+	if claims.Iat == nil {
+		return errors.New("missing issued-at claim")
+	}
+	if skew := time.Since(time.Unix(*claims.Iat, 0)); skew > jwtMaxClockSkew || skew < -jwtMaxClockSkew {
+		return errors.New("stale token")
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// loadJWTSecret reads the hex-encoded secret from the given file, or generates one there if the file does not exist
+func loadJWTSecret(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+		if err != nil || len(secret) != jwtSecretLength {
+			return nil, fmt.Errorf("invalid JWT secret in %v, expecting %v hex-encoded bytes", file, jwtSecretLength)
+		}
+		return secret, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	secret := make([]byte, jwtSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(file, []byte(hex.EncodeToString(secret)), 0600); err != nil {
+		return nil, err
+	}
+	logger.Infof("Generated a JWT secret at %v", file)
+	return secret, nil
+}
+
+// stopAuth closes the authenticated endpoint and the IPC endpoint. The WS connections are drained, and
+// the handler of the authenticated endpoint stopped, by the caller.
+func stopAuth(ctx context.Context) {
+	if authServer != nil {
+		if err := authServer.Shutdown(ctx); err != nil {
+			logger.Warnf("Authenticated endpoint closed before the in-flight requests completed: %v", err)
+		}
+		authServer = nil
+		logger.Infof("Authenticated endpoint closed")
+	}
+	if ipcListener != nil {
+		ipcListener.Close()
+		ipcListener = nil
+		logger.Infof("IPC endpoint closed")
+	}
+	if ipcHandler != nil {
+		ipcHandler.Stop()
+		ipcHandler = nil
+	}
+}
@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testJWTSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func encodeJWTPart(part string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(part))
+}
+
+func signJWT(secret []byte, header string, claims string) string {
+	unsigned := encodeJWTPart(header) + "." + encodeJWTPart(claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	header := `{"alg":"HS256","typ":"JWT"}`
+	now := time.Now().Unix()
+	claims := fmt.Sprintf(`{"iat":%v}`, now)
+	valid := signJWT(testJWTSecret, header, claims)
+
+	// replacePart returns the valid token with one of its parts replaced
+	replacePart := func(i int, part string) string {
+		parts := strings.Split(valid, ".")
+		parts[i] = part
+		return strings.Join(parts, ".")
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantErr       bool
+	}{
+		{"valid", "Bearer " + valid, false},
+		{"within clock skew", "Bearer " + signJWT(testJWTSecret, header, fmt.Sprintf(`{"iat":%v}`, now-30)), false},
+		{"missing authorization", "", true},
+		{"missing bearer scheme", valid, true},
+		{"two parts", "Bearer " + encodeJWTPart(header) + "." + encodeJWTPart(claims), true},
+		{"malformed header encoding", "Bearer " + replacePart(0, "!!!"), true},
+		{"malformed header json", "Bearer " + signJWT(testJWTSecret, `{"alg":`, claims), true},
+		{"alg none", "Bearer " + signJWT(testJWTSecret, `{"alg":"none","typ":"JWT"}`, claims), true},
+		{"alg HS512", "Bearer " + signJWT(testJWTSecret, `{"alg":"HS512","typ":"JWT"}`, claims), true},
+		{"wrong secret", "Bearer " + signJWT([]byte("another secret"), header, claims), true},
+		{"tampered claims", "Bearer " + replacePart(1, encodeJWTPart(fmt.Sprintf(`{"iat":%v}`, now+1))), true},
+		{"tampered signature", "Bearer " + replacePart(2, encodeJWTPart("signature")), true},
+		{"malformed signature", "Bearer " + replacePart(2, "!!!"), true},
+		{"missing iat", "Bearer " + signJWT(testJWTSecret, header, `{}`), true},
+		{"stale iat", "Bearer " + signJWT(testJWTSecret, header, fmt.Sprintf(`{"iat":%v}`, now-120)), true},
+		{"future iat", "Bearer " + signJWT(testJWTSecret, header, fmt.Sprintf(`{"iat":%v}`, now+120)), true},
+	}
+
+	for _, test := range tests {
+		err := verifyJWT(testJWTSecret, test.authorization)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: verifyJWT() error = %v, wantErr %v", test.name, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/spf13/viper"
+)
+
+// knownModules are the namespaces which can be exposed through rpc.httpModules and rpc.wsModules
+var knownModules = map[string]bool{
+	netNamespace:   true,
+	ethNamespace:   true,
+	web3Namespace:  true,
+	evmNamespace:   true,
+	debugNamespace: true,
+}
+
+// loadAccessConfig reads the exposed modules, the allowed origins and the virtual hosts from
+// the config, and rejects the invalid ones so that a typo does not silently open or close access
+func loadAccessConfig() (err error) {
+	if HTTPModules, err = parseModules(common.CfgRPCHttpModules); err != nil {
+		return err
+	}
+	if WSModules, err = parseModules(common.CfgRPCWSModules); err != nil {
+		return err
+	}
+	if httpOrigins, err = parseOrigins(common.CfgRPCCorsOrigins); err != nil {
+		return err
+	}
+	if wsOrigins, err = parseOrigins(common.CfgRPCWSOrigins); err != nil {
+		return err
+	}
+	if httpVirtualHosts, err = parseVirtualHosts(common.CfgRPCVirtualHosts); err != nil {
+		return err
+	}
+	privateMethods = newMethodSet(configList(common.CfgRPCPrivateMethods))
+
+	logger.Infof("HTTP modules: %v, WS modules: %v", HTTPModules, WSModules)
+	logger.Infof("CORS origins: %v, WS origins: %v, virtual hosts: %v", httpOrigins, wsOrigins, httpVirtualHosts)
+	if len(privateMethods) > 0 {
+		logger.Infof("Private methods: %v", configList(common.CfgRPCPrivateMethods))
+	}
+	return nil
+}
+
+func parseModules(key string) ([]string, error) {
+	modules := []string{}
+	for _, module := range configList(key) {
+		if !knownModules[module] {
+			return nil, fmt.Errorf("invalid %v: unknown module %v", key, module)
+		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
+
+func parseOrigins(key string) ([]string, error) {
+	origins := configList(key)
+	for i, origin := range origins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid %v: %v is not an origin such as https://example.com", key, origin)
+		}
+		origins[i] = strings.TrimSuffix(origin, "/") // browsers send the origin without a trailing slash
+	}
+	return origins, nil
+}
+
+func parseVirtualHosts(key string) ([]string, error) {
+	vhosts := configList(key)
+	for _, vhost := range vhosts {
+		if vhost == "*" {
+			continue
+		}
+		if strings.ContainsAny(vhost, "/:@ ") {
+			return nil, fmt.Errorf("invalid %v: %v is not a host name", key, vhost)
+		}
+	}
+	return vhosts, nil
+}
+
+// configList reads a list given either as a YAML sequence or as a comma-separated string
+func configList(key string) []string {
+	list := []string{}
+	for _, item := range viper.GetStringSlice(key) {
+		for _, value := range strings.Split(item, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				list = append(list, value)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// writeTimeoutMargin leaves the time to write the response of a request canceled after rpc.timeoutSecs
+const writeTimeoutMargin = 5 * time.Second
+
+var errListenerClosed = errors.New("listener closed")
+
+// limitListener caps the number of connections open at the same time. The listeners created
+// with the same slots share the cap.
+type limitListener struct {
+	net.Listener
+	slots     chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// newLimitListener wraps l so that it waits for a free slot before accepting a connection. A nil
+// slots channel leaves the number of connections unbounded.
+func newLimitListener(l net.Listener, slots chan struct{}) net.Listener {
+	if slots == nil {
+		return l
+	}
+	return &limitListener{Listener: l, slots: slots, done: make(chan struct{})}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-l.done:
+		return nil, errListenerClosed
+	}
+
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+	return &limitConn{Conn: conn, release: func() { <-l.slots }}, nil
+}
+
+func (l *limitListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+type limitConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
+
+// newTimeoutHandler cancels the context of the requests which are not served within the timeout
+func newTimeoutHandler(next http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
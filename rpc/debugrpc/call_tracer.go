@@ -0,0 +1,252 @@
+package debugrpc
+
+import (
+	"math/big"
+	"time"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/evm"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/common/hexutil"
+)
+
+const (
+	// errExecutionReverted is the error of the reverted calls, as reported by the geth callTracer
+	errExecutionReverted = "execution reverted"
+	// errInternalFailure is the error of the calls which failed without a fault, e.g. the calls
+	// beyond the maximum depth, as reported by the geth callTracer
+	errInternalFailure = "internal failure"
+)
+
+// CallFrame is a call frame in the output format of the geth callTracer
+type CallFrame struct {
+	Type         string           `json:"type"`
+	From         tcommon.Address  `json:"from"`
+	To           *tcommon.Address `json:"to,omitempty"`
+	Value        *hexutil.Big     `json:"value,omitempty"`
+	Gas          hexutil.Uint64   `json:"gas"`
+	GasUsed      hexutil.Uint64   `json:"gasUsed"`
+	Input        hexutil.Bytes    `json:"input"`
+	Output       hexutil.Bytes    `json:"output,omitempty"`
+	Error        string           `json:"error,omitempty"`
+	RevertReason string           `json:"revertReason,omitempty"`
+	Calls        []*CallFrame     `json:"calls,omitempty"`
+
+	// While the call runs
+	gasIn    uint64 // gas of the caller before the call
+	gasCost  uint64 // cost of the call opcode
+	gasKnown bool   // whether Gas was read from the first opcode of the call
+	outOff   uint64 // where the caller expects the output in its memory
+	outLen   uint64
+}
+
+type callTracerConfig struct {
+	OnlyTopCall bool `json:"onlyTopCall"`
+}
+
+// callTracer is the geth callTracer, which reports the tree of the calls and contract
+// creations. geth's EVM only notifies the tracers of the opcodes, so the calls are tracked as
+// the geth JavaScript callTracer does: a frame is pushed by the opcodes which call or create,
+// and popped by the first opcode run back at the depth of the caller.
+type callTracer struct {
+	config    callTracerConfig
+	callstack []*CallFrame // callstack[0] is the top-level call
+	descended bool         // whether the last opcode entered a call
+}
+
+func newCallTracer(config callTracerConfig) *callTracer {
+	return &callTracer{
+		config:    config,
+		callstack: []*CallFrame{{}},
+	}
+}
+
+// CaptureStart implements vm.Tracer
+func (t *callTracer) CaptureStart(from gethcommon.Address, to gethcommon.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	top := t.callstack[0]
+	top.Type = vm.CALL.String()
+	if create {
+		top.Type = vm.CREATE.String()
+	}
+	top.From = tcommon.Address(from)
+	callee := tcommon.Address(to)
+	top.To = &callee
+	top.Input = append([]byte{}, input...)
+	top.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	return nil
+}
+
+// CaptureState implements vm.Tracer
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if err != nil {
+		t.fault(err)
+		return nil
+	}
+	if t.config.OnlyTopCall {
+		return nil
+	}
+
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		t.callstack = append(t.callstack, &CallFrame{
+			Type:    op.String(),
+			From:    tcommon.Address(contract.Address()),
+			Input:   memoryCopy(memory, stack.Back(1), stack.Back(2)),
+			Value:   (*hexutil.Big)(new(big.Int).Set(stack.Back(0))),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return nil
+	case vm.SELFDESTRUCT:
+		to := tcommon.Address(gethcommon.BigToAddress(stack.Back(0)))
+		t.addCall(&CallFrame{
+			Type:  op.String(),
+			From:  tcommon.Address(contract.Address()),
+			To:    &to,
+			Value: (*hexutil.Big)(env.StateDB.GetBalance(contract.Address())),
+		})
+		return nil
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		to := gethcommon.BigToAddress(stack.Back(1))
+		if _, ok := vm.PrecompiledContractsIstanbul[to]; ok {
+			return nil // the precompiles are just expensive opcodes
+		}
+		off := 1 // the position of the value, which DELEGATECALL and STATICCALL do not take
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		callee := tcommon.Address(to)
+		frame := &CallFrame{
+			Type:    op.String(),
+			From:    tcommon.Address(contract.Address()),
+			To:      &callee,
+			Input:   memoryCopy(memory, stack.Back(2+off), stack.Back(3+off)),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  stack.Back(4 + off).Uint64(),
+			outLen:  stack.Back(5 + off).Uint64(),
+		}
+		if off == 1 {
+			frame.Value = (*hexutil.Big)(new(big.Int).Set(stack.Back(2)))
+		}
+		t.callstack = append(t.callstack, frame)
+		t.descended = true
+		return nil
+	}
+
+	// The gas given to a call is only known from within, after the 63/64 rule and the stipend
+	if t.descended {
+		if depth >= len(t.callstack) {
+			frame := t.callstack[len(t.callstack)-1]
+			frame.Gas, frame.gasKnown = hexutil.Uint64(gas), true
+		}
+		t.descended = false
+	}
+
+	if op == vm.REVERT {
+		frame := t.callstack[len(t.callstack)-1]
+		frame.Error = errExecutionReverted
+		frame.Output = memoryCopy(memory, stack.Back(0), stack.Back(1))
+		frame.RevertReason, _ = common.UnpackRevert(frame.Output)
+		return nil
+	}
+
+	// Back at the depth of the caller: the last call returned
+	if depth == len(t.callstack)-1 {
+		frame := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+		success := stack.Back(0).Sign() != 0
+
+		if frame.Type == vm.CREATE.String() || frame.Type == vm.CREATE2.String() {
+			frame.GasUsed = hexutil.Uint64(frame.gasIn - frame.gasCost - gas)
+			if success {
+				address := gethcommon.BigToAddress(stack.Back(0))
+				to := tcommon.Address(address)
+				frame.To = &to
+				frame.Output = env.StateDB.GetCode(address)
+			} else if frame.Error == "" {
+				frame.Error = errInternalFailure
+			}
+		} else if frame.gasKnown {
+			frame.GasUsed = hexutil.Uint64(frame.gasIn - frame.gasCost + uint64(frame.Gas) - gas)
+			if success {
+				frame.Output = memoryCopy(memory, new(big.Int).SetUint64(frame.outOff), new(big.Int).SetUint64(frame.outLen))
+			} else if frame.Error == "" {
+				frame.Error = errInternalFailure
+			}
+		}
+		t.addCall(frame)
+	}
+	return nil
+}
+
+// CaptureFault implements vm.Tracer
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	t.fault(err)
+	return nil
+}
+
+// fault fails the current call, which consumes all its gas
+func (t *callTracer) fault(err error) {
+	t.descended = false
+	frame := t.callstack[len(t.callstack)-1]
+	if frame.Error != "" {
+		return // already reverted
+	}
+	frame.Error = err.Error()
+	if len(t.callstack) == 1 {
+		return // the top-level call is completed by CaptureEnd
+	}
+	t.callstack = t.callstack[:len(t.callstack)-1]
+	if frame.gasKnown {
+		frame.GasUsed = frame.Gas
+	}
+	t.addCall(frame)
+}
+
+// CaptureEnd implements vm.Tracer
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	top := t.callstack[0]
+	top.Output = append([]byte{}, output...)
+	if err != nil && common.IsRevert(err.Error()) {
+		top.Error = errExecutionReverted
+		top.RevertReason, _ = common.UnpackRevert(output)
+	} else if err != nil {
+		top.Error = err.Error()
+		top.Output = nil
+	}
+	return nil
+}
+
+// addCall appends a completed call to the calls of its caller
+func (t *callTracer) addCall(frame *CallFrame) {
+	caller := t.callstack[len(t.callstack)-1]
+	caller.Calls = append(caller.Calls, frame)
+}
+
+func (t *callTracer) result(msg evm.Message, res *evm.Result) (interface{}, error) {
+	top := t.callstack[0]
+	top.Gas = hexutil.Uint64(msg.Gas)
+	top.GasUsed = hexutil.Uint64(res.UsedGas)
+	if top.Type == vm.CREATE.String() && res.Failed() {
+		top.To = nil
+	}
+	return top, nil
+}
+
+// memoryCopy returns a copy of the memory slice, zero padded past the memory the EVM has
+// allocated so far
+func memoryCopy(memory *vm.Memory, offset, size *big.Int) []byte {
+	if !size.IsUint64() || size.Sign() == 0 {
+		return []byte{}
+	}
+	data := memory.Data()
+	cpy := make([]byte, size.Uint64())
+	if offset.IsUint64() && offset.Uint64() < uint64(len(data)) {
+		copy(cpy, data[offset.Uint64():])
+	}
+	return cpy
+}
@@ -0,0 +1,53 @@
+package debugrpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/evm"
+	"github.com/spf13/viper"
+)
+
+// ------------------------------- debug_traceCall -----------------------------------
+
+// TraceCall executes the call with a local EVM, see evm.Executor, and returns the trace of the
+// requested tracer. "tag" is a block number, a tag or an EIP-1898 object, see common.BlockRef,
+// the call starts from the state after that block.
+func (d *DebugRPCService) TraceCall(ctx context.Context, argObj common.EthSmartContractArgObj, tag *common.BlockRef, config *TraceConfig) (interface{}, error) {
+	logger.Infof("debug_traceCall called, tx: %+v, block: %v, tracer: %v", argObj, tag, tracerName(config))
+
+	tracer, err := newTracer(config)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel, err := withTimeout(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	blockGasLimit := viper.GetUint64(common.CfgScriptBlockGasLimit)
+	gas, err := strconv.ParseUint(argObj.Gas, 16, 64)
+	if err != nil || gas > blockGasLimit {
+		argObj.Gas = "0x" + fmt.Sprintf("%x", blockGasLimit)
+	}
+
+	sctx, err := common.GenerateSctx(argObj, 0)
+	if err != nil {
+		logger.Errorf("debug_traceCall: Failed to generate smart contract transaction: %+v\n", argObj)
+		return nil, err
+	}
+
+	executor, err := evm.NewCallExecutor(ctx, d.backend, tag)
+	if err != nil {
+		return nil, err
+	}
+	msg := evm.NewMessage(sctx)
+	result, err := executor.Apply(msg, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return tracer.result(msg, result)
+}
@@ -0,0 +1,143 @@
+package debugrpc
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+	trpc "github.com/scripttoken/script/rpc"
+	rpcc "github.com/ybbus/jsonrpc"
+)
+
+// mockBackend serves the latest block and a few contracts with empty storage, the other
+// methods of ScriptBackend are not used by the tests and panic
+type mockBackend struct {
+	backend.ScriptBackend
+
+	codes map[string][]byte
+}
+
+func (b *mockBackend) GetStatus(ctx context.Context) (*trpc.GetStatusResult, error) {
+	return &trpc.GetStatusResult{ChainID: "privatenet", LatestFinalizedBlockHeight: 100}, nil
+}
+
+func (b *mockBackend) GetBlockByHeight(ctx context.Context, height tcommon.JSONUint64) (*common.ScriptGetBlockResultInner, error) {
+	return &common.ScriptGetBlockResultInner{Height: height, Timestamp: (*tcommon.JSONBig)(big.NewInt(1700000000))}, nil
+}
+
+func (b *mockBackend) GetAccount(ctx context.Context, address string, height tcommon.JSONUint64, preview bool) (*types.Account, error) {
+	code, ok := b.codes[address]
+	if !ok {
+		return nil, &backend.RPCError{Method: "script.GetAccount",
+			Err: &rpcc.RPCError{Code: -32000, Message: "Account with address " + address + " is not found"}}
+	}
+	return &types.Account{CodeHash: tcommon.BytesToHash(crypto.Keccak256(code))}, nil
+}
+
+func (b *mockBackend) GetCode(ctx context.Context, address string, height tcommon.JSONUint64) (string, error) {
+	return hex.EncodeToString(b.codes[address]), nil
+}
+
+func (b *mockBackend) GetStorageAt(ctx context.Context, address string, storagePosition string, height tcommon.JSONUint64) (string, error) {
+	return "", nil
+}
+
+func TestTraceCall(t *testing.T) {
+	caller := tcommon.HexToAddress("0x2000000000000000000000000000000000000002")
+	callee := tcommon.HexToAddress("0x3000000000000000000000000000000000000003")
+	reverter := tcommon.HexToAddress("0x4000000000000000000000000000000000000004")
+
+	// call(gas(), callee, 0, 0, 0, 0, 32), return(0, 32)
+	callerCode := append([]byte{0x60, 0x20, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x73}, callee.Bytes()...)
+	callerCode = append(callerCode, 0x5a, 0xf1, 0x60, 0x20, 0x60, 0x00, 0xf3)
+	d := &DebugRPCService{backend: &mockBackend{codes: map[string][]byte{
+		caller.Hex(): callerCode,
+		// mstore(0, 7), return(0, 32)
+		callee.Hex(): {0x60, 0x07, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3},
+		// revert(0, 0)
+		reverter.Hex(): {0x60, 0x00, 0x60, 0x00, 0xfd},
+	}}}
+
+	callTracer := callTracerName
+	tests := []struct {
+		name   string
+		to     tcommon.Address
+		config *TraceConfig
+		check  func(result interface{}) string
+	}{
+		{
+			name:   "callTracer",
+			to:     caller,
+			config: &TraceConfig{Tracer: &callTracer},
+			check: func(result interface{}) string {
+				frame := result.(*CallFrame)
+				if frame.Type != "CALL" || *frame.To != caller || frame.Error != "" || len(frame.Calls) != 1 {
+					return "unexpected top-level call"
+				}
+				inner := frame.Calls[0]
+				if inner.Type != "CALL" || inner.From != caller || *inner.To != callee || inner.Error != "" {
+					return "unexpected inner call"
+				}
+				if inner.GasUsed == 0 || uint64(inner.GasUsed) > uint64(inner.Gas) || uint64(frame.GasUsed) <= uint64(inner.GasUsed) {
+					return "unexpected gas used"
+				}
+				if new(big.Int).SetBytes(inner.Output).Int64() != 7 || new(big.Int).SetBytes(frame.Output).Int64() != 7 {
+					return "unexpected output"
+				}
+				return ""
+			},
+		},
+		{
+			name:   "callTracer revert",
+			to:     reverter,
+			config: &TraceConfig{Tracer: &callTracer},
+			check: func(result interface{}) string {
+				if frame := result.(*CallFrame); frame.Error != errExecutionReverted {
+					return "revert not reported"
+				}
+				return ""
+			},
+		},
+		{
+			name: "struct logger",
+			to:   caller,
+			check: func(result interface{}) string {
+				executionResult := result.(*ExecutionResult)
+				// 8 opcodes up to the call, 6 in the callee, 3 to return
+				if executionResult.Failed || len(executionResult.StructLogs) != 17 {
+					return "unexpected struct logs"
+				}
+				if log := executionResult.StructLogs[7]; log.Op != "CALL" || log.Depth != 1 || log.Stack == nil || log.Memory != nil {
+					return "unexpected CALL struct log"
+				}
+				if log := executionResult.StructLogs[8]; log.Depth != 2 {
+					return "callee not traced"
+				}
+				return ""
+			},
+		},
+	}
+
+	for _, test := range tests {
+		argObj := common.EthSmartContractArgObj{From: tcommon.HexToAddress("0x01"), To: test.to, Data: "0x"}
+		result, err := d.TraceCall(context.Background(), argObj, nil, test.config)
+		if err != nil {
+			t.Errorf("%v: TraceCall() error = %v", test.name, err)
+			continue
+		}
+		if problem := test.check(result); problem != "" {
+			t.Errorf("%v: TraceCall() = %+v: %v", test.name, result, problem)
+		}
+	}
+
+	unknownTracer := "prestateTracer"
+	if _, err := d.TraceCall(context.Background(), common.EthSmartContractArgObj{To: caller}, nil, &TraceConfig{Tracer: &unknownTracer}); err == nil {
+		t.Errorf("TraceCall() with an unsupported tracer succeeded")
+	}
+}
@@ -0,0 +1,84 @@
+package debugrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/evm"
+
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/scripttoken/script/ledger/types"
+	trpc "github.com/scripttoken/script/rpc"
+)
+
+// ------------------------------- debug_traceTransaction -----------------------------------
+
+// TraceTransaction replays the tx with a local EVM, see evm.Executor, and returns the trace of
+// the requested tracer. The tx starts from the state after the previous block, updated by the
+// smart contract txs before it in its block. The other txs of the block, e.g. the transfers,
+// are not replayed.
+func (d *DebugRPCService) TraceTransaction(ctx context.Context, hashStr string, config *TraceConfig) (interface{}, error) {
+	logger.Infof("debug_traceTransaction called, txHash: %v, tracer: %v", hashStr, tracerName(config))
+
+	tracer, err := newTracer(config)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel, err := withTimeout(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	var scriptGetTransactionResult *trpc.GetTransactionResult
+	err = d.retry.Do(ctx, func() (err error) { // It might take some time for a tx to be finalized, retry a few times
+		scriptGetTransactionResult, err = d.backend.GetTransaction(ctx, hashStr)
+		if err != nil {
+			return err
+		}
+		if (scriptGetTransactionResult.BlockHash == tcommon.Hash{}) {
+			return backend.ErrNotFinalized
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Warnf("debug_traceTransaction failed, err: %v", err)
+		return nil, err
+	}
+	if types.TxType(scriptGetTransactionResult.Type) != types.TxSmartContract || scriptGetTransactionResult.Tx == nil {
+		return nil, fmt.Errorf("transaction %v is not a smart contract transaction", hashStr)
+	}
+
+	block, err := d.backend.GetBlock(ctx, scriptGetTransactionResult.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	if block.Height < 2 {
+		return nil, fmt.Errorf("the state before height %v is not available", block.Height)
+	}
+
+	state := evm.NewStateDB(ctx, d.backend, block.Height-1)
+	executor, err := evm.NewExecutor(ctx, d.backend, state, evm.NewBlockContext(block))
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range block.Txs {
+		if tx.Hash == scriptGetTransactionResult.TxHash {
+			break
+		}
+		if types.TxType(tx.Type) != types.TxSmartContract || tx.Tx == nil {
+			continue
+		}
+		if _, err := executor.Apply(evm.NewMessage(tx.Tx.(*types.SmartContractTx)), nil); err != nil {
+			return nil, fmt.Errorf("failed to replay transaction %v: %w", tx.Hash.Hex(), err)
+		}
+	}
+
+	msg := evm.NewMessage(scriptGetTransactionResult.Tx.(*types.SmartContractTx))
+	result, err := executor.Apply(msg, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return tracer.result(msg, result)
+}
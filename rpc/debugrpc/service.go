@@ -0,0 +1,35 @@
+package debugrpc
+
+import (
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "debugrpc"})
+
+// DebugRPCService provides an API to access to the Debug endpoints. It is not public: the
+// namespace is only exposed through authrpc.modules, or when listed in rpc.httpModules or rpc.wsModules.
+type DebugRPCService struct {
+	backend backend.ScriptBackend
+	retry   backend.RetryPolicy
+}
+
+// NewDebugRPCService creates a new API for the geth compatible debug RPC interface
+func NewDebugRPCService(namespace string, b backend.ScriptBackend) erpclib.API {
+	if namespace == "" {
+		namespace = "debug"
+	}
+
+	service := &DebugRPCService{
+		backend: b,
+		retry:   backend.RetryPolicyFromConfig(),
+	}
+
+	return erpclib.API{
+		Namespace: namespace,
+		Version:   "1.0",
+		Service:   service,
+		Public:    false,
+	}
+}
@@ -0,0 +1,156 @@
+package debugrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/scripttoken/script-eth-rpc-adaptor/evm"
+)
+
+const (
+	callTracerName = "callTracer"
+
+	// defaultTraceTimeout bounds the traces which set no timeout, as geth does
+	defaultTraceTimeout = 5 * time.Second
+)
+
+// TraceConfig holds the tracing options of debug_traceTransaction and debug_traceCall, as
+// accepted by geth
+type TraceConfig struct {
+	Tracer         *string         `json:"tracer"`
+	Timeout        *string         `json:"timeout"`
+	DisableStorage bool            `json:"disableStorage"`
+	DisableStack   bool            `json:"disableStack"`
+	EnableMemory   bool            `json:"enableMemory"`
+	TracerConfig   json.RawMessage `json:"tracerConfig,omitempty"`
+}
+
+// tracer is a vm.Tracer which renders what it traced in the JSON format of a geth tracer
+type tracer interface {
+	vm.Tracer
+	result(msg evm.Message, res *evm.Result) (interface{}, error)
+}
+
+func tracerName(config *TraceConfig) string {
+	if config == nil || config.Tracer == nil {
+		return ""
+	}
+	return *config.Tracer
+}
+
+// newTracer returns the tracer requested by the config: the default struct logger, or the callTracer
+func newTracer(config *TraceConfig) (tracer, error) {
+	switch name := tracerName(config); name {
+	case "":
+		return newStructLogger(config), nil
+	case callTracerName:
+		var tracerConfig callTracerConfig
+		if len(config.TracerConfig) > 0 {
+			if err := json.Unmarshal(config.TracerConfig, &tracerConfig); err != nil {
+				return nil, fmt.Errorf("invalid %v config: %v", name, err)
+			}
+		}
+		return newCallTracer(tracerConfig), nil
+	default:
+		return nil, fmt.Errorf("tracer %v is not supported, only %v and the default struct logger are", name, callTracerName)
+	}
+}
+
+// withTimeout bounds ctx by the timeout of the trace config, or by defaultTraceTimeout
+func withTimeout(ctx context.Context, config *TraceConfig) (context.Context, context.CancelFunc, error) {
+	timeout := defaultTraceTimeout
+	if config != nil && config.Timeout != nil {
+		var err error
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return ctx, func() {}, fmt.Errorf("invalid trace timeout %v: %v", *config.Timeout, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, nil
+}
+
+// ExecutionResult is the output format of the default geth tracer
+type ExecutionResult struct {
+	Gas         uint64         `json:"gas"`
+	Failed      bool           `json:"failed"`
+	ReturnValue string         `json:"returnValue"`
+	StructLogs  []StructLogRes `json:"structLogs"`
+}
+
+// StructLogRes is an opcode in the output format of the default geth tracer
+type StructLogRes struct {
+	Pc      uint64             `json:"pc"`
+	Op      string             `json:"op"`
+	Gas     uint64             `json:"gas"`
+	GasCost uint64             `json:"gasCost"`
+	Depth   int                `json:"depth"`
+	Error   string             `json:"error,omitempty"`
+	Stack   *[]string          `json:"stack,omitempty"`
+	Memory  *[]string          `json:"memory,omitempty"`
+	Storage *map[string]string `json:"storage,omitempty"`
+}
+
+// structLogger is the default geth tracer, which logs every opcode with the stack, the
+// memory and the storage written so far
+type structLogger struct {
+	*vm.StructLogger
+}
+
+func newStructLogger(config *TraceConfig) *structLogger {
+	logConfig := &vm.LogConfig{DisableMemory: true}
+	if config != nil {
+		logConfig.DisableMemory = !config.EnableMemory
+		logConfig.DisableStack = config.DisableStack
+		logConfig.DisableStorage = config.DisableStorage
+	}
+	return &structLogger{StructLogger: vm.NewStructLogger(logConfig)}
+}
+
+func (l *structLogger) result(msg evm.Message, res *evm.Result) (interface{}, error) {
+	logs := l.StructLogs()
+	structLogs := make([]StructLogRes, len(logs))
+	for i, log := range logs {
+		structLogs[i] = StructLogRes{
+			Pc:      log.Pc,
+			Op:      log.Op.String(),
+			Gas:     log.Gas,
+			GasCost: log.GasCost,
+			Depth:   log.Depth,
+		}
+		if log.Err != nil {
+			structLogs[i].Error = log.Err.Error()
+		}
+		if log.Stack != nil {
+			stack := make([]string, len(log.Stack))
+			for j, value := range log.Stack {
+				stack[j] = fmt.Sprintf("%x", math.PaddedBigBytes(value, 32))
+			}
+			structLogs[i].Stack = &stack
+		}
+		if log.Memory != nil {
+			memory := make([]string, 0, (len(log.Memory)+31)/32)
+			for j := 0; j+32 <= len(log.Memory); j += 32 {
+				memory = append(memory, fmt.Sprintf("%x", log.Memory[j:j+32]))
+			}
+			structLogs[i].Memory = &memory
+		}
+		if log.Storage != nil {
+			storage := make(map[string]string, len(log.Storage))
+			for key, value := range log.Storage {
+				storage[fmt.Sprintf("%x", key)] = fmt.Sprintf("%x", value)
+			}
+			structLogs[i].Storage = &storage
+		}
+	}
+
+	return &ExecutionResult{
+		Gas:         res.UsedGas,
+		Failed:      res.Failed(),
+		ReturnValue: fmt.Sprintf("%x", res.ReturnData),
+		StructLogs:  structLogs,
+	}, nil
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	hexutil "github.com/scripttoken/script/common/hexutil"
 )
 
@@ -13,7 +13,7 @@ import (
 func (e *EthRPCService) BlockNumber(ctx context.Context) (result string, err error) {
 	logger.Infof("eth_blockNumber called")
 
-	blockNumber, err := common.GetCurrentHeight()
+	blockNumber, err := backend.GetCurrentHeight(ctx, e.backend)
 
 	if err != nil {
 		return "", err
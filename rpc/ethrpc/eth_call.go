@@ -4,22 +4,37 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
-	"time"
+	"strings"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/evm"
 	"github.com/spf13/viper"
 
+	tcommon "github.com/scripttoken/script/common"
 	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
+// missingStateError is part of the error the Script node returns when the state of a height
+// is no longer available, the trie.MissingNodeError of its ledger
+const missingStateError = "missing trie node"
+
+type extendedCallArgs struct {
+	SctxBytes string             `json:"sctx_bytes"`
+	Height    tcommon.JSONUint64 `json:"height,omitempty"`
+}
+
 // ------------------------------- eth_call -----------------------------------
 
-// Note: "tag" could be an integer block number, or the string "latest", "earliest" or "pending". So its type needs to be interface{}
-func (e *EthRPCService) Call(ctx context.Context, argObj common.EthSmartContractArgObj, tag interface{}) (result string, err error) {
-	logger.Infof("eth_call called, tx: %+v", argObj)
+// Note: "tag" could be a block number, a tag such as "latest" or "earliest", or an EIP-1898 object, see common.BlockRef.
+// The calls at a past height are forwarded to the Script RPC method set by script.extendedCallMethod, and fail without it.
+// The calls with state or block overrides are executed with a local EVM instead, see evm.Executor.
+func (e *EthRPCService) Call(ctx context.Context, argObj common.EthSmartContractArgObj, tag *common.BlockRef,
+	overrides *common.StateOverride, blockOverrides *common.BlockOverrides) (result string, err error) {
+	logger.Infof("eth_call called, tx: %+v, block: %v", argObj, tag)
 
 	blockGasLimit := viper.GetUint64(common.CfgScriptBlockGasLimit)
 	gas, err := strconv.ParseUint(argObj.Gas, 16, 64)
@@ -27,43 +42,115 @@ func (e *EthRPCService) Call(ctx context.Context, argObj common.EthSmartContract
 		argObj.Gas = "0x" + fmt.Sprintf("%x", blockGasLimit)
 	}
 
-	sctxBytes, err := common.GetSctxBytes(argObj)
+	if overrides != nil || blockOverrides != nil {
+		return e.callWithOverrides(ctx, argObj, tag, overrides, blockOverrides)
+	}
+
+	sequence, err := backend.GetSeqByAddress(ctx, e.backend, argObj.From)
+	if err != nil {
+		logger.Errorf("eth_call: Failed to get the sequence of %v: %v", argObj.From.Hex(), err)
+		return "", err
+	}
+	sctxBytes, err := common.GetSctxBytes(argObj, sequence)
 	if err != nil {
 		logger.Errorf("eth_call: Failed to get smart contract bytes: %+v\n", argObj)
 		return result, err
 	}
 
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		trpcResult := trpc.CallSmartContractResult{}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		logger.Infof("eth_call Script RPC result: %+v\n", trpcResult)
-		if len(trpcResult.VmError) > 0 {
-			return trpcResult.GasUsed, fmt.Errorf(trpcResult.VmError)
-		}
-		return trpcResult.VmReturn, nil
+	callResult, err := e.callSmartContract(ctx, sctxBytes, tag)
+	if err != nil {
+		logger.Infof("eth_call error: %v", err)
+		return "", err
+	}
+	logger.Infof("eth_call Script RPC result: %+v\n", callResult)
+	if len(callResult.VmError) > 0 {
+		logger.Infof("eth_call error: %v", callResult.VmError)
+		return "", common.RecordErrorData(ctx, common.NewVMError(callResult.VmError, callResult.VmReturn))
 	}
+	result = "0x" + callResult.VmReturn
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
+	logger.Infof("eth_call result: %v", result)
 
-	maxRetry := 4
-	for i := 0; i < maxRetry; i++ { // It might take some time for a tx to be finalized, retry a few times
-		rpcRes, rpcErr := client.Call("script.CallSmartContract", trpc.CallSmartContractArgs{SctxBytes: hex.EncodeToString(sctxBytes)})
-		//logger.Infof("eth_call rpcRes: %v, rpcErr: %v", rpcRes, rpcErr)
+	return result, nil
+}
 
-		resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
-		if err != nil {
-			if i == maxRetry-1 {
-				logger.Infof("eth_call error: %v", err)
-				return "", err
-			}
-			time.Sleep(blockInterval) // one block duration
-		} else {
-			result = "0x" + resultIntf.(string)
-			break
-		}
+// callWithOverrides executes the call with a local EVM, which starts from the state after the
+// given block with the overrides applied
+func (e *EthRPCService) callWithOverrides(ctx context.Context, argObj common.EthSmartContractArgObj, tag *common.BlockRef,
+	overrides *common.StateOverride, blockOverrides *common.BlockOverrides) (result string, err error) {
+	sctx, err := common.GenerateSctx(argObj, 0)
+	if err != nil {
+		logger.Errorf("eth_call: Failed to generate smart contract transaction: %+v\n", argObj)
+		return "", err
+	}
+
+	executor, err := evm.NewCallExecutor(ctx, e.backend, tag)
+	if err != nil {
+		return "", err
+	}
+	if err := executor.ApplyOverrides(overrides, blockOverrides); err != nil {
+		return "", err
+	}
+	callResult, err := executor.Apply(evm.NewMessage(sctx), nil)
+	if err != nil {
+		logger.Infof("eth_call error: %v", err)
+		return "", err
+	}
+	if callResult.Failed() {
+		logger.Infof("eth_call error: %v", callResult.Err)
+		return "", common.RecordErrorData(ctx, common.NewVMError(callResult.Err.Error(), hex.EncodeToString(callResult.ReturnData)))
 	}
+	result = "0x" + hex.EncodeToString(callResult.ReturnData)
 
 	logger.Infof("eth_call result: %v", result)
 
 	return result, nil
 }
+
+// callSmartContract executes the call at the given block. The calls at the latest finalized
+// height are served by script.CallSmartContract, the calls at a past height, "earliest"
+// included, by the Script RPC method set by script.extendedCallMethod. Without such a method
+// the historical state is not available, and the calls at a past height fail.
+func (e *EthRPCService) callSmartContract(ctx context.Context, sctxBytes []byte, ref *common.BlockRef) (callResult *trpc.CallSmartContractResult, err error) {
+	height, isLatest, err := backend.ResolveBlockRef(ctx, e.backend, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if isLatest {
+		err = e.retry.Do(ctx, func() (err error) {
+			callResult, err = e.backend.CallSmartContract(ctx, sctxBytes)
+			return err
+		})
+		return callResult, err
+	}
+
+	method := viper.GetString(common.CfgScriptExtendedCallMethod)
+	if method == "" {
+		return nil, fmt.Errorf("historical state not available, calls at height %v require %v to be set", height, common.CfgScriptExtendedCallMethod)
+	}
+
+	args := extendedCallArgs{
+		SctxBytes: hex.EncodeToString(sctxBytes),
+		Height:    height,
+	}
+
+	var jsonBytes json.RawMessage
+	err = e.retry.Do(ctx, func() (err error) {
+		jsonBytes, err = e.backend.RawCall(ctx, method, args)
+		return err
+	})
+	var rpcErr *backend.RPCError
+	if errors.As(err, &rpcErr) && strings.Contains(rpcErr.Err.Message, missingStateError) {
+		return nil, fmt.Errorf("state at height %v is not available, it may have been pruned: %v", height, rpcErr.Err.Message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	callResult = &trpc.CallSmartContractResult{}
+	if err := json.Unmarshal(jsonBytes, callResult); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", method, err)
+	}
+	return callResult, nil
+}
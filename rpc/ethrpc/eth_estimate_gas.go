@@ -2,54 +2,161 @@ package ethrpc
 
 import (
 	"context"
-	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
 	tcommon "github.com/scripttoken/script/common"
 	hexutil "github.com/scripttoken/script/common/hexutil"
+	trpc "github.com/scripttoken/script/rpc"
 	"github.com/spf13/viper"
+)
 
-	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
+const (
+	txGas                 uint64 = 21000 // gas charged for a call transaction
+	txGasContractCreation uint64 = 53000 // gas charged for a contract creation transaction
+	txDataZeroGas         uint64 = 4     // gas charged for each zero byte of the data
+	txDataNonZeroGas      uint64 = 16    // gas charged for each non-zero byte of the data
 )
 
+var errInsufficientFunds = errors.New("insufficient funds for transfer")
+
 // ------------------------------- eth_estimateGas -----------------------------------
 
-func (e *EthRPCService) EstimateGas(ctx context.Context, argObj common.EthSmartContractArgObj) (result string, err error) {
-	logger.Infof("eth_estimateGas called")
+// Note: the optional "tag" is a block parameter as in eth_call, the estimation runs against the state at that block.
+// As geth does, the estimation binary-searches the lowest gas limit the call succeeds with, between the intrinsic
+// gas and the block gas limit, or the gas the sender can afford at the given gas price if that is lower. The result
+// includes the margin set by estimateGas.marginPercent.
+func (e *EthRPCService) EstimateGas(ctx context.Context, argObj common.EthSmartContractArgObj, tag *common.BlockRef) (result string, err error) {
+	logger.Infof("eth_estimateGas called, block: %v", tag)
 
-	sctxBytes, err := common.GetSctxBytes(argObj)
+	data, err := common.HexToBytes(argObj.Data)
 	if err != nil {
-		logger.Errorf("eth_estimateGas: Failed to get smart contract bytes: %+v\n", argObj)
-		return result, err
+		return "", fmt.Errorf("invalid data: %v", err)
 	}
+	lo := intrinsicGas(data, argObj.To == tcommon.Address{}) - 1
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-
-	rpcRes, rpcErr := client.Call("script.CallSmartContract", trpc.CallSmartContractArgs{SctxBytes: hex.EncodeToString(sctxBytes)})
+	hi := viper.GetUint64(common.CfgScriptBlockGasLimit)
+	if argObj.Gas != "" {
+		if gas := common.Str2hex2unit(argObj.Gas); gas > lo && gas < hi {
+			hi = gas
+		}
+	}
+	allowance, err := e.gasAllowance(ctx, argObj, tag)
+	if err != nil {
+		return "", err
+	}
+	cappedByBalance := allowance != nil && allowance.IsUint64() && allowance.Uint64() < hi
+	if cappedByBalance {
+		logger.Debugf("eth_estimateGas: gas limit capped by the balance of %v to %v", argObj.From.Hex(), allowance)
+		hi = allowance.Uint64()
+	}
+	gasCap := hi
 
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		trpcResult := trpc.CallSmartContractResult{}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		if len(trpcResult.VmError) > 0 {
-			logger.Warnf("eth_estimateGas: EVM execution failed: %v\n", trpcResult.VmError)
-			return trpcResult.GasUsed, fmt.Errorf(trpcResult.VmError)
+	sequence, err := backend.GetSeqByAddress(ctx, e.backend, argObj.From)
+	if err != nil {
+		logger.Errorf("eth_estimateGas: Failed to get the sequence of %v: %v", argObj.From.Hex(), err)
+		return "", err
+	}
+	execute := func(gas uint64) (*trpc.CallSmartContractResult, error) {
+		argObj.Gas = hexutil.EncodeUint64(gas)
+		sctxBytes, err := common.GetSctxBytes(argObj, sequence)
+		if err != nil {
+			logger.Errorf("eth_estimateGas: Failed to get smart contract bytes: %+v\n", argObj)
+			return nil, err
 		}
-		return trpcResult.GasUsed, nil
+		return e.callSmartContract(ctx, sctxBytes, tag)
 	}
 
-	resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
+	// The call must succeed with the highest allowed gas limit, otherwise it would fail with any limit
+	callResult, err := execute(hi)
 	if err != nil {
 		return "", err
 	}
+	if len(callResult.VmError) > 0 {
+		logger.Warnf("eth_estimateGas: EVM execution failed: %v\n", callResult.VmError)
+		if cappedByBalance && !common.IsRevert(callResult.VmError) {
+			return "", fmt.Errorf("gas required exceeds allowance (%v)", gasCap)
+		}
+		return "", common.RecordErrorData(ctx, common.NewVMError(callResult.VmError, callResult.VmReturn))
+	}
+
+	// The gas used is a lower bound, the gas limit may need to be higher because of the 63/64 rule,
+	// or because the contract checks the remaining gas
+	if callResult.GasUsed > 0 && uint64(callResult.GasUsed)-1 > lo {
+		lo = uint64(callResult.GasUsed) - 1
+	}
 
-	blockGasLimit := viper.GetUint64(common.CfgScriptBlockGasLimit)
-	estimatedGasWithMargin := uint64(1.1 * float64(resultIntf.(tcommon.JSONUint64))) // result should be way below the MAX_UINT_64, so no need to check for overflow
-	if estimatedGasWithMargin >= blockGasLimit {
-		estimatedGasWithMargin = blockGasLimit
+	maxIterations := viper.GetInt(common.CfgEstimateGasMaxIterations)
+	for i := 0; lo+1 < hi && i < maxIterations; i++ {
+		mid := lo + (hi-lo)/2
+		callResult, err := execute(mid)
+		if err != nil {
+			return "", err
+		}
+		if len(callResult.VmError) > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+
+	margin := viper.GetUint64(common.CfgEstimateGasMarginPercent)
+	estimatedGasWithMargin := hi + hi*margin/100 // result should be way below the MAX_UINT_64, so no need to check for overflow
+	if estimatedGasWithMargin > gasCap {
+		estimatedGasWithMargin = gasCap
 	}
 	result = hexutil.EncodeUint64(estimatedGasWithMargin)
 	return result, nil
 }
+
+// gasAllowance returns the gas the sender can pay for at the given gas price once the value is
+// transferred, or nil if no gas price or sender is given
+func (e *EthRPCService) gasAllowance(ctx context.Context, argObj common.EthSmartContractArgObj, tag *common.BlockRef) (*big.Int, error) {
+	if argObj.GasPrice == "" || (argObj.From == tcommon.Address{}) {
+		return nil, nil
+	}
+	gasPrice := common.HexStrToBigInt(argObj.GasPrice)
+	if gasPrice.Sign() <= 0 {
+		return nil, nil
+	}
+
+	height, err := backend.ResolveStateHeight(ctx, e.backend, tag)
+	if err != nil {
+		return nil, err
+	}
+	account, err := e.backend.GetAccount(ctx, argObj.From.Hex(), height, false)
+	if err != nil {
+		return nil, err
+	}
+	balance := new(big.Int)
+	if account.Balance.SPAYWei != nil {
+		balance.Set(account.Balance.SPAYWei)
+	}
+	if argObj.Value != "" {
+		value := common.HexStrToBigInt(argObj.Value)
+		if value.Cmp(balance) > 0 {
+			return nil, errInsufficientFunds
+		}
+		balance.Sub(balance, value)
+	}
+	return balance.Div(balance, gasPrice), nil
+}
+
+// intrinsicGas returns the gas charged for a transaction before any EVM execution
+func intrinsicGas(data []byte, isContractCreation bool) uint64 {
+	gas := txGas
+	if isContractCreation {
+		gas = txGasContractCreation
+	}
+	for _, b := range data {
+		if b == 0 {
+			gas += txDataZeroGas
+		} else {
+			gas += txDataNonZeroGas
+		}
+	}
+	return gas
+}
@@ -0,0 +1,89 @@
+package ethrpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/gasprice"
+	"github.com/spf13/viper"
+
+	"github.com/scripttoken/script/common/hexutil"
+)
+
+type EthFeeHistoryResult struct {
+	OldestBlock   hexutil.Uint64 `json:"oldestBlock"`
+	BaseFeePerGas []string       `json:"baseFeePerGas"`
+	GasUsedRatio  []float64      `json:"gasUsedRatio"`
+	Reward        [][]string     `json:"reward,omitempty"`
+}
+
+// ------------------------------- eth_feeHistory -----------------------------------
+
+// FeeHistory returns the emulated base fees and the tips at the given percentiles of the
+// blockCount blocks up to newestBlock, see the gasprice package for how they are emulated
+func (e *EthRPCService) FeeHistory(ctx context.Context, blockCount interface{}, newestBlock string, rewardPercentiles []float64) (result EthFeeHistoryResult, err error) {
+	logger.Infof("eth_feeHistory called, blockCount: %v, newestBlock: %v, rewardPercentiles: %v", blockCount, newestBlock, rewardPercentiles)
+
+	result = EthFeeHistoryResult{BaseFeePerGas: []string{}, GasUsedRatio: []float64{}}
+
+	count, err := parseBlockCount(blockCount)
+	if err != nil {
+		return result, err
+	}
+
+	lastBlock := common.GetHeightByTag(newestBlock)
+	if lastBlock == math.MaxUint64 {
+		lastBlock, err = backend.GetCurrentHeight(ctx, e.backend)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	history, err := gasprice.GetFeeHistory(ctx, e.backend, e.retry, count, lastBlock, rewardPercentiles,
+		getDefaultGasPrice(ctx, e.backend), viper.GetUint64(common.CfgScriptBlockGasLimit))
+	if err != nil {
+		return result, err
+	}
+
+	result.OldestBlock = hexutil.Uint64(history.OldestBlock)
+	for _, baseFee := range history.BaseFees {
+		result.BaseFeePerGas = append(result.BaseFeePerGas, "0x"+baseFee.Text(16))
+	}
+	result.GasUsedRatio = append(result.GasUsedRatio, history.GasUsedRatio...)
+	for _, rewards := range history.Rewards {
+		result.Reward = append(result.Reward, bigsToHex(rewards))
+	}
+
+	return result, nil
+}
+
+// parseBlockCount accepts the block count either as a number or as a hex or decimal string,
+// since the clients do not agree on its encoding
+func parseBlockCount(blockCount interface{}) (uint64, error) {
+	switch val := blockCount.(type) {
+	case float64:
+		if val < 0 || val != math.Trunc(val) {
+			return 0, fmt.Errorf("invalid block count: %v", val)
+		}
+		return uint64(val), nil
+	case string:
+		count, ok := new(big.Int).SetString(val, 0)
+		if !ok || !count.IsUint64() {
+			return 0, fmt.Errorf("invalid block count: %v", val)
+		}
+		return count.Uint64(), nil
+	}
+	return 0, fmt.Errorf("invalid block count type: %v", blockCount)
+}
+
+func bigsToHex(vals []*big.Int) []string {
+	result := make([]string, len(vals))
+	for i, val := range vals {
+		result[i] = "0x" + val.Text(16)
+	}
+	return result
+}
@@ -4,19 +4,17 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"math/big"
 	"strings"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/gasprice"
 	tcommon "github.com/scripttoken/script/common"
 	"github.com/scripttoken/script/common/hexutil"
 	tcrypto "github.com/scripttoken/script/crypto"
 	"github.com/scripttoken/script/ledger/types"
 	"github.com/spf13/viper"
-
-	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 // ------------------------------- eth_getBlockByHash -----------------------------------
@@ -31,88 +29,118 @@ func (e *EthRPCService) GetBlockByHash(ctx context.Context, hashStr string, txDe
 	chainID := new(big.Int)
 	chainID.SetString(chainIDStr, 16)
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-	rpcRes, rpcErr := client.Call("script.GetBlock", trpc.GetBlockArgs{Hash: tcommon.HexToHash(hashStr)})
-	if rpcErr != nil {
-		logger.Errorf("eth_getBlockByHash, error: %v", rpcErr)
+	block, err := e.backend.GetBlock(ctx, tcommon.HexToHash(hashStr))
+	if err != nil {
+		logger.Errorf("eth_getBlockByHash, error: %v", err)
+		return result, err
 	}
-	return GetBlockFromTRPCResult(chainID, rpcRes, rpcErr, txDetails)
+	return e.getEthBlock(chainID, block, txDetails)
 }
 
-func GetBlockFromTRPCResult(chainID *big.Int, rpcRes *rpcc.RPCResponse, rpcErr error, txDetails bool) (result common.EthGetBlockResult, err error) {
-	result = common.EthGetBlockResult{}
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		trpcResult := common.ScriptGetBlockResult{}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		if trpcResult.ScriptGetBlockResultInner == nil {
-			return result, errors.New("empty block")
-		}
-		result.Transactions = make([]interface{}, 0)
-		if txDetails {
-			var objmap map[string]json.RawMessage
-			json.Unmarshal(jsonBytes, &objmap)
-			if objmap["transactions"] != nil {
-				var txmaps []map[string]json.RawMessage
-				json.Unmarshal(objmap["transactions"], &txmaps)
-				for i, omap := range txmaps {
-					if types.TxType(trpcResult.Txs[i].Type) == types.TxSmartContract {
-						scTx := types.SmartContractTx{}
-						json.Unmarshal(omap["raw"], &scTx)
-
-						var ethTx common.EthGetTransactionResult
-
-						ethTx.BlockHash = trpcResult.Hash
-						ethTx.BlockHeight = hexutil.Uint64(trpcResult.Height)
-
-						ethTx.From = scTx.From.Address
-						if (scTx.To.Address == tcommon.Address{}) {
-							ethTx.To = nil // conform to ETH standard
-						} else {
-							ethTx.To = &scTx.To.Address
-						}
-						ethTx.GasPrice = "0x" + scTx.GasPrice.Text(16)
-						ethTx.Gas = hexutil.Uint64(scTx.GasLimit)
-						ethTx.Value = "0x" + scTx.From.Coins.SPAYWei.Text(16)
-						ethTx.Input = "0x" + hex.EncodeToString(scTx.Data)
-						sigData := scTx.From.Signature.ToBytes()
-						ethTx.Nonce = hexutil.Uint64(scTx.From.Sequence) - 1 // off-by-one: Ethereum's account nonce starts from 0, while Script's account sequnce starts from 1
-						//ethTx.TxHash = GetEthTxHash(chainID, ethTx)
-
-						txBytes, _ := types.TxToBytes(&scTx)
-						ethTx.TxHash = tcrypto.Keccak256Hash(txBytes)
-
-						GetRSVfromSignature(sigData, &ethTx)
-
-						result.Transactions = append(result.Transactions, ethTx)
-						result.GasUsed = hexutil.Uint64(trpcResult.Txs[i].Receipt.GasUsed)
-					}
-				}
-			}
-		}
-		return trpcResult, nil
+type ethBlockKey struct {
+	hash      tcommon.Hash
+	txDetails bool
+}
+
+// getEthBlock converts the block with GetBlockFromTRPCResult, the conversions of the
+// finalized blocks are cached
+func (e *EthRPCService) getEthBlock(chainID *big.Int, block *common.ScriptGetBlockResultInner, txDetails bool) (common.EthGetBlockResult, error) {
+	if block == nil || !block.Status.IsFinalized() {
+		return GetBlockFromTRPCResult(chainID, block, txDetails)
 	}
-	resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
+
+	key := ethBlockKey{hash: block.Hash, txDetails: txDetails}
+	if result, ok := e.ethBlocks.Get(key); ok {
+		return result.(common.EthGetBlockResult), nil
+	}
+
+	result, err := GetBlockFromTRPCResult(chainID, block, txDetails)
 	if err != nil {
 		return result, err
 	}
-	script_GetBlockResult := resultIntf.(common.ScriptGetBlockResult)
-	result.Height = hexutil.Uint64(script_GetBlockResult.Height)
-	result.Hash = script_GetBlockResult.Hash
-	result.Parent = script_GetBlockResult.Parent
-	result.Timestamp = hexutil.Uint64(script_GetBlockResult.Timestamp.ToInt().Uint64())
-	result.Proposer = script_GetBlockResult.Proposer
-	result.TxHash = script_GetBlockResult.TxHash
-	result.StateHash = script_GetBlockResult.StateHash
+	resultBytes, err := json.Marshal(result)
+	if err == nil {
+		e.ethBlocks.Add(key, result, int64(len(resultBytes)))
+	}
+	return result, nil
+}
+
+func GetBlockFromTRPCResult(chainID *big.Int, block *common.ScriptGetBlockResultInner, txDetails bool) (result common.EthGetBlockResult, err error) {
+	result = common.EthGetBlockResult{}
+	if block == nil {
+		return result, backend.ErrEmptyBlock
+	}
+
+	baseFee := gasprice.BaseFee(block)
+	if baseFee == nil && chainID != nil {
+		baseFee = gasprice.DefaultGasPrice(chainID.Uint64())
+	}
+
+	result.Transactions = make([]interface{}, 0)
+	if txDetails {
+		for _, tx := range block.Txs {
+			if types.TxType(tx.Type) != types.TxSmartContract || tx.Tx == nil {
+				continue
+			}
+			scTx := tx.Tx.(*types.SmartContractTx)
+
+			var ethTx common.EthGetTransactionResult
+
+			ethTx.BlockHash = block.Hash
+			ethTx.BlockHeight = hexutil.Uint64(block.Height)
+
+			ethTx.From = scTx.From.Address
+			if (scTx.To.Address == tcommon.Address{}) {
+				ethTx.To = nil // conform to ETH standard
+			} else {
+				ethTx.To = &scTx.To.Address
+			}
+			ethTx.GasPrice = "0x" + scTx.GasPrice.Text(16)
+			ethTx.Gas = hexutil.Uint64(scTx.GasLimit)
+			ethTx.Value = "0x" + scTx.From.Coins.SPAYWei.Text(16)
+			ethTx.Input = "0x" + hex.EncodeToString(scTx.Data)
+			sigData := scTx.From.Signature.ToBytes()
+			ethTx.Nonce = hexutil.Uint64(scTx.From.Sequence) - 1 // off-by-one: Ethereum's account nonce starts from 0, while Script's account sequnce starts from 1
+			//ethTx.TxHash = GetEthTxHash(chainID, ethTx)
+
+			txBytes, _ := types.TxToBytes(scTx)
+			ethTx.TxHash = tcrypto.Keccak256Hash(txBytes)
+
+			GetRSVfromSignature(sigData, &ethTx)
+
+			result.Transactions = append(result.Transactions, ethTx)
+		}
+	}
+
+	result.Height = hexutil.Uint64(block.Height)
+	result.Hash = block.Hash
+	result.Parent = block.Parent
+	result.Timestamp = hexutil.Uint64(block.Timestamp.ToInt().Uint64())
+	result.Proposer = block.Proposer
+	result.StateHash = block.StateHash
 	result.GasLimit = hexutil.Uint64(viper.GetUint64(common.CfgScriptBlockGasLimit))
-	result.Size = 1000
 
-	for _, tx := range script_GetBlockResult.Txs {
+	// The roots, the gas used and the size are derived from the smart contract txs and their
+	// receipts, which are the txs listed in the Ethereum view of the block
+	blockData := common.DeriveEthBlockData(block)
+	bloom := common.BlockBloom(block)
+	header := common.EthHeader(block, blockData, bloom, uint64(result.GasLimit))
+	result.TxHash = tcommon.Hash(blockData.TxsRoot)
+	result.ReiceptHash = tcommon.Hash(blockData.ReceiptsRoot)
+	result.Sha3Uncles = tcommon.Hash(header.UncleHash)
+	result.GasUsed = hexutil.Uint64(blockData.GasUsed)
+	result.Size = hexutil.Uint64(common.EthBlockSize(header, blockData.TxsRlp))
+
+	for _, tx := range block.Txs {
 		if !txDetails && types.TxType(tx.Type) == types.TxSmartContract {
 			result.Transactions = append(result.Transactions, tx.Hash)
 		}
 	}
 
-	result.LogsBloom = "0x00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"
+	result.LogsBloom = bloom.Hex()
+	if baseFee != nil {
+		result.BaseFeePerGas = "0x" + baseFee.Text(16)
+	}
 	result.ExtraData = "0x"
 	result.Nonce = "0x0000000000000000"
 	result.Uncles = []tcommon.Hash{}
@@ -4,12 +4,9 @@ import (
 	"context"
 	"math"
 	"math/big"
-	"time"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
-
-	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 // ------------------------------- eth_getBlockByNumber -----------------------------------
@@ -18,7 +15,7 @@ func (e *EthRPCService) GetBlockByNumber(ctx context.Context, numberStr string,
 	height := common.GetHeightByTag(numberStr)
 
 	if height == math.MaxUint64 {
-		height, err = common.GetCurrentHeight()
+		height, err = backend.GetCurrentHeight(ctx, e.backend)
 		if err != nil {
 			return result, err
 		}
@@ -32,21 +29,14 @@ func (e *EthRPCService) GetBlockByNumber(ctx context.Context, numberStr string,
 	chainID := new(big.Int)
 	chainID.SetString(chainIDStr, 16)
 
-	maxRetry := 5
-	for i := 0; i < maxRetry; i++ { // It might take some time for a block to be finalized, retry a few times
-		client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-		rpcRes, rpcErr := client.Call("script.GetBlockByHeight", trpc.GetBlockByHeightArgs{
-			Height: height})
-
-		//logger.Infof("eth_getBlockByNumber, rpcRes: %v, rpcRes.Rsult: %v", rpcRes, rpcRes.Result)
-
-		result, err = GetBlockFromTRPCResult(chainID, rpcRes, rpcErr, txDetails)
-		if err == nil {
-			return result, err
-		}
-
-		time.Sleep(blockInterval) // one block duration
+	var block *common.ScriptGetBlockResultInner
+	err = e.retry.Do(ctx, func() (err error) { // It might take some time for a block to be finalized, retry a few times
+		block, err = e.backend.GetBlockByHeight(ctx, height)
+		return err
+	})
+	if err != nil {
+		return result, err
 	}
 
-	return result, err
+	return e.getEthBlock(chainID, block, txDetails)
 }
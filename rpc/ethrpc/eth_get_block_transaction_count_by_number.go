@@ -5,11 +5,10 @@ import (
 	"math"
 	"math/big"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
 
 	hexutil "github.com/scripttoken/script/common/hexutil"
-	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 // ------------------------------- eth_getBlockTransactionCountByNumber -----------------------------------
@@ -17,7 +16,7 @@ func (e *EthRPCService) GetBlockTransactionCountByNumber(ctx context.Context, nu
 	logger.Infof("eth_getBlockTransactionCountByNumber called")
 	height := common.GetHeightByTag(numberStr)
 	if height == math.MaxUint64 {
-		height, err = common.GetCurrentHeight()
+		height, err = backend.GetCurrentHeight(ctx, e.backend)
 		if err != nil {
 			return result, err
 		}
@@ -31,9 +30,10 @@ func (e *EthRPCService) GetBlockTransactionCountByNumber(ctx context.Context, nu
 	chainID := new(big.Int)
 	chainID.SetString(chainIDStr, 16)
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-	rpcRes, rpcErr := client.Call("script.GetBlockByHeight", trpc.GetBlockByHeightArgs{
-		Height: height})
-	block, err := GetBlockFromTRPCResult(chainID, rpcRes, rpcErr, false)
+	scriptBlock, err := e.backend.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return result, err
+	}
+	block, err := e.getEthBlock(chainID, scriptBlock, false)
 	return hexutil.Uint64(len(block.Transactions)), err
 }
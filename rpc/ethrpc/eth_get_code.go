@@ -2,49 +2,25 @@ package ethrpc
 
 import (
 	"context"
-	"encoding/json"
-	"math"
 	"strings"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
-
-	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 // ------------------------------- eth_getCode -----------------------------------
 
-func (e *EthRPCService) GetCode(ctx context.Context, address string, tag string) (result string, err error) {
-	logger.Infof("eth_getCode called")
+func (e *EthRPCService) GetCode(ctx context.Context, address string, tag *common.BlockRef) (result string, err error) {
+	logger.Infof("eth_getCode called, address: %v, block: %v", address, tag)
 
-	height := common.GetHeightByTag(tag)
-	if height == math.MaxUint64 {
-		height = 0 // 0 is interpreted as the last height by the script.GetAccount method
+	height, err := backend.ResolveStateHeight(ctx, e.backend, tag)
+	if err != nil {
+		return "", err
 	}
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-
-	// maxRetry := 3
-	maxRetry := 1
-	for i := 0; i < maxRetry; i++ { // It might take some time for a tx to be finalized, retry a few times
-
-		rpcRes, rpcErr := client.Call("script.GetCode", trpc.GetCodeArgs{Address: address, Height: height})
-
-		parse := func(jsonBytes []byte) (interface{}, error) {
-			trpcResult := trpc.GetCodeResult{}
-			json.Unmarshal(jsonBytes, &trpcResult)
-			return trpcResult.Code, nil
-		}
-
-		resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
-		if err != nil {
-			return result, err
-		}
-
-		result = resultIntf.(string)
-		if result == "" { // might need to wait for the tx to be finalized
-			// time.Sleep(blockInterval) // one block duration
-		}
+	result, err = e.backend.GetCode(ctx, address, height)
+	if err != nil {
+		return result, err
 	}
 
 	if result == "" {
@@ -0,0 +1,71 @@
+package ethrpc
+
+import (
+	"context"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/spf13/viper"
+
+	tcommon "github.com/scripttoken/script/common"
+)
+
+// ------------------------------- eth_getFilterChanges -----------------------------------
+
+// GetFilterChanges returns what happened since the last poll of the filter: the matching logs
+// for a logs filter, and the block or tx hashes for a block or pending tx filter. At most
+// query.getLogsBlockRange blocks are scanned per poll, the remaining ones are returned by the
+// next polls.
+func (e *EthRPCService) GetFilterChanges(ctx context.Context, id erpclib.ID) (interface{}, error) {
+	logger.Infof("eth_getFilterChanges called, id: %v", id)
+
+	f, err := e.filters.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.typ == pendingTxFilter {
+		return e.filters.takeTxHashes(id)
+	}
+
+	currentHeight, err := backend.GetCurrentHeight(ctx, e.backend)
+	if err != nil {
+		return nil, err
+	}
+
+	start := f.cursor + 1
+	end := currentHeight
+	if f.typ == logsFilter && f.toBlock < end {
+		end = f.toBlock
+	}
+	if blockRangeLimit := tcommon.JSONUint64(viper.GetUint64(common.CfgQueryGetLogsBlockRange)); blockRangeLimit > 0 && end >= start && end-start+1 > blockRangeLimit {
+		end = start + blockRangeLimit - 1
+	}
+
+	blocks := []*common.ScriptGetBlockResultInner{}
+	if start <= end {
+		err = e.retry.Do(ctx, func() (err error) {
+			blocks, err = e.backend.GetBlocksByRange(ctx, start, end)
+			return err
+		})
+		if err != nil {
+			return nil, err // keep the cursor, the blocks will be scanned again by the next poll
+		}
+		e.filters.advance(id, end)
+	}
+
+	if f.typ == blocksFilter {
+		blockHashes := []tcommon.Hash{}
+		for _, block := range blocks {
+			if block != nil {
+				blockHashes = append(blockHashes, block.Hash)
+			}
+		}
+		return blockHashes, nil
+	}
+
+	result := []EthGetLogsResult{}
+	extractLogs(f.addresses, f.topicsFilter, f.filterByAddress, blocks, &result)
+	return result, nil
+}
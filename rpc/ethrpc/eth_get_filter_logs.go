@@ -0,0 +1,25 @@
+package ethrpc
+
+import (
+	"context"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+)
+
+// ------------------------------- eth_getFilterLogs -----------------------------------
+
+// GetFilterLogs returns all the logs matching a filter installed by eth_newFilter, over the
+// block range of the filter, same as eth_getLogs
+func (e *EthRPCService) GetFilterLogs(ctx context.Context, id erpclib.ID) ([]EthGetLogsResult, error) {
+	logger.Infof("eth_getFilterLogs called, id: %v", id)
+
+	f, err := e.filters.get(id)
+	if err != nil {
+		return []EthGetLogsResult{}, err
+	}
+	if f.typ != logsFilter {
+		return []EthGetLogsResult{}, errFilterNotFound
+	}
+
+	return e.GetLogs(ctx, f.args)
+}
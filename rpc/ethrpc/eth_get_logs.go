@@ -8,14 +8,14 @@ import (
 	"math"
 	"time"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/logindex"
 	"github.com/spf13/viper"
 
 	tcommon "github.com/scripttoken/script/common"
 	hexutil "github.com/scripttoken/script/common/hexutil"
 	"github.com/scripttoken/script/ledger/types"
-	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 // type EthGetLogsArgs struct {
@@ -68,12 +68,27 @@ func (e *EthRPCService) GetLogs(ctx context.Context, args EthGetLogsArgs) (resul
 		return result, err
 	}
 
-	maxRetry := 5
+	filterByAddress := shouldFilterByAddress(addresses)
+	logger.Debugf("filterByAddress: %v, addresses: %v", filterByAddress, addresses)
+
 	blocks := []*common.ScriptGetBlockResultInner{}
 	if args.Blockhash.Hex() != "0x0000000000000000000000000000000000000000000000000000000000000000" {
-		err = retrieveBlockByHash(args.Blockhash, &blocks, maxRetry)
+		err = retrieveBlockByHash(ctx, e.backend, e.retry, args.Blockhash, &blocks)
 	} else {
-		err = retrieveBlocksByRange(args.FromBlock, args.ToBlock, &blocks, maxRetry)
+		var blockStart, blockEnd tcommon.JSONUint64
+		blockStart, blockEnd, err = getBlockRange(ctx, e.backend, args.FromBlock, args.ToBlock)
+		if err != nil {
+			return result, err
+		}
+
+		// The indexed ranges are not subject to the block range limit, but to the result limit
+		if e.logIndex != nil && e.logIndex.Covers(blockStart, blockEnd) {
+			err = extractIndexedLogs(e.logIndex, blockStart, blockEnd, addresses, topicsFilter, filterByAddress, &result)
+			logger.Infof("eth_getLogs, served from the log index, blockStart: %v, blockEnd: %v, time: %v", blockStart, blockEnd, time.Since(start))
+			return result, err
+		}
+
+		err = retrieveBlocksByRange(ctx, e.backend, e.retry, blockStart, blockEnd, &blocks)
 	}
 	if err != nil {
 		return result, err
@@ -82,9 +97,6 @@ func (e *EthRPCService) GetLogs(ctx context.Context, args EthGetLogsArgs) (resul
 	queryBlocksTime := time.Since(start)
 	start = time.Now()
 
-	filterByAddress := !(len(addresses) == 0 || (len(addresses) == 1) && (addresses[0] == tcommon.Address{}))
-	logger.Debugf("filterByAddress: %v, addresses: %v", filterByAddress, addresses)
-
 	extractLogs(addresses, topicsFilter, filterByAddress, blocks, &result)
 
 	resultJson, _ := json.Marshal(result)
@@ -139,6 +151,12 @@ func parseTopicsFilter(argsTopics []interface{}) ([][]tcommon.Hash, error) {
 	return topicsFilter, nil
 }
 
+// shouldFilterByAddress tells whether the logs need to be filtered by the parsed addresses,
+// no address or a single zero address matches all the logs
+func shouldFilterByAddress(addresses []tcommon.Address) bool {
+	return !(len(addresses) == 0 || (len(addresses) == 1) && (addresses[0] == tcommon.Address{}))
+}
+
 func addressMatch(addresses []tcommon.Address, contractAddress tcommon.Address) bool {
 	for _, address := range addresses {
 		if address == contractAddress {
@@ -190,78 +208,32 @@ func topicIncludedIn(logTopic tcommon.Hash, topicList []tcommon.Hash) bool {
 	return false
 }
 
-func retrieveBlockByHash(blockhash tcommon.Hash, blocks *[](*common.ScriptGetBlockResultInner), maxRetry int) (err error) {
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		//logger.Infof("eth_getLogs.parse, jsonBytes: %v", string(jsonBytes))
-
-		trpcResult := common.ScriptGetBlockResult{ScriptGetBlockResultInner: &common.ScriptGetBlockResultInner{}}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		var objmap map[string]json.RawMessage
-		json.Unmarshal(jsonBytes, &objmap)
-		if objmap["transactions"] != nil {
-			txs := []trpc.Tx{}
-			json.Unmarshal(objmap["transactions"], &txs)
-			trpcResult.Txs = txs
-		}
-		return trpcResult, nil
-	}
-
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-
-	var block common.ScriptGetBlockResult
-	for i := 0; i < maxRetry; i++ { // It might take some time for a tx to be finalized, retry a few times
-		if i == maxRetry {
-			return fmt.Errorf("failed to retrieve block %v", blockhash.Hex())
-		}
-
-		rpcRes, rpcErr := client.Call("script.GetBlock", trpc.GetBlockArgs{Hash: blockhash})
-		resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
-		if err == nil {
-			block = resultIntf.(common.ScriptGetBlockResult)
-			break
-		}
-
+func retrieveBlockByHash(ctx context.Context, b backend.ScriptBackend, retry backend.RetryPolicy, blockhash tcommon.Hash, blocks *[](*common.ScriptGetBlockResultInner)) (err error) {
+	var block *common.ScriptGetBlockResultInner
+	err = retry.Do(ctx, func() (err error) { // It might take some time for a tx to be finalized, retry a few times
+		block, err = b.GetBlock(ctx, blockhash)
+		return err
+	})
+	if err != nil {
 		logger.Warnf("eth_getLogs, script.GetBlock returned error: %v", err)
-		time.Sleep(blockInterval) // one block duration
+		return err
 	}
 
-	if block.ScriptGetBlockResultInner != nil {
-		(*blocks) = append((*blocks), block.ScriptGetBlockResultInner)
+	if block != nil {
+		(*blocks) = append((*blocks), block)
 	}
 
 	return nil
 }
 
-func retrieveBlocksByRange(fromBlock string, toBlock string, blocks *[](*common.ScriptGetBlockResultInner), maxRetry int) (err error) {
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		//logger.Infof("eth_getLogs.parse, jsonBytes: %v", string(jsonBytes))
-
-		trpcResult := common.ScriptGetBlocksResult{}
-
-		var objList []json.RawMessage
-		json.Unmarshal(jsonBytes, &objList)
-		for _, blockJsonBytes := range objList {
-			getBlockResult := common.ScriptGetBlockResult{ScriptGetBlockResultInner: &common.ScriptGetBlockResultInner{}}
-			json.Unmarshal(blockJsonBytes, &getBlockResult)
-			var objmap map[string]json.RawMessage
-			json.Unmarshal(blockJsonBytes, &objmap)
-			if objmap["transactions"] != nil {
-				txs := []trpc.Tx{}
-				json.Unmarshal(objmap["transactions"], &txs)
-				getBlockResult.Txs = txs
-			}
-			trpcResult = append(trpcResult, getBlockResult.ScriptGetBlockResultInner)
-		}
-
-		return trpcResult, nil
-	}
-
-	currentHeight, err := common.GetCurrentHeight()
+// getBlockRange resolves the fromBlock and toBlock tags of a query into heights
+func getBlockRange(ctx context.Context, b backend.ScriptBackend, fromBlock string, toBlock string) (blockStart tcommon.JSONUint64, blockEnd tcommon.JSONUint64, err error) {
+	currentHeight, err := backend.GetCurrentHeight(ctx, b)
 	if err != nil {
-		return err
+		return blockStart, blockEnd, err
 	}
 
-	blockStart := currentHeight
+	blockStart = currentHeight
 	if fromBlock != "" {
 		blockStart = common.GetHeightByTag(fromBlock)
 		if blockStart == math.MaxUint64 {
@@ -269,7 +241,7 @@ func retrieveBlocksByRange(fromBlock string, toBlock string, blocks *[](*common.
 		}
 	}
 
-	blockEnd := currentHeight
+	blockEnd = currentHeight
 	if toBlock != "" {
 		blockEnd = common.GetHeightByTag(toBlock)
 		if blockEnd == math.MaxUint64 {
@@ -286,6 +258,10 @@ func retrieveBlocksByRange(fromBlock string, toBlock string, blocks *[](*common.
 	// 	blockStart -= 2 // Script requires two consecutive committed blocks for finalization
 	// }
 
+	return blockStart, blockEnd, nil
+}
+
+func retrieveBlocksByRange(ctx context.Context, b backend.ScriptBackend, retry backend.RetryPolicy, blockStart tcommon.JSONUint64, blockEnd tcommon.JSONUint64, blocks *[](*common.ScriptGetBlockResultInner)) (err error) {
 	blockRangeLimit := viper.GetUint64(common.CfgQueryGetLogsBlockRange)
 	queryBlockRange := blockEnd - blockStart + 1
 
@@ -295,29 +271,20 @@ func retrieveBlocksByRange(fromBlock string, toBlock string, blocks *[](*common.
 		return fmt.Errorf("block range too large, we currently allow querying for at most %v blocks at a time (start: %v, end: %v)", blockRangeLimit, blockStart, blockEnd)
 	}
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-	for i := 0; i < maxRetry; i++ { // It might take some time for a tx to be finalized, retry a few times
-		if i == maxRetry {
-			return fmt.Errorf("failed to retrieve blocks from %v to %v", blockStart, blockEnd)
-		}
-
-		rpcRes, rpcErr := client.Call("script.GetBlocksByRange", trpc.GetBlocksByRangeArgs{Start: tcommon.JSONUint64(blockStart), End: tcommon.JSONUint64(blockEnd)})
-		resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
-		if err != nil {
-			blocks = &[]*common.ScriptGetBlockResultInner{}
-			logger.Warnf("eth_getLogs, script.GetBlocksByRange returned error: %v", err)
-			time.Sleep(blockInterval) // one block duration
-			continue
-		}
+	var getBlocksRes common.ScriptGetBlocksResult
+	err = retry.Do(ctx, func() (err error) { // It might take some time for a tx to be finalized, retry a few times
+		getBlocksRes, err = b.GetBlocksByRange(ctx, blockStart, blockEnd)
+		return err
+	})
+	if err != nil {
+		logger.Warnf("eth_getLogs, script.GetBlocksByRange returned error: %v", err)
+		return err
+	}
 
-		getBlocksRes := resultIntf.(common.ScriptGetBlocksResult)
-		for _, block := range getBlocksRes {
-			if block != nil {
-				*blocks = append((*blocks), block)
-			}
+	for _, block := range getBlocksRes {
+		if block != nil {
+			*blocks = append((*blocks), block)
 		}
-
-		break
 	}
 
 	return nil
@@ -365,3 +332,40 @@ func extractLogs(addresses []tcommon.Address, topicsFilter [][]tcommon.Hash, fil
 		}
 	}
 }
+
+// extractIndexedLogs looks up the logs of the blocks in [blockStart, blockEnd] in the log
+// index, and applies the same filters as extractLogs to them. The indexed ranges are not
+// subject to the block range limit, so the lookup stops with an error once more than
+// query.getLogsMaxResults logs match.
+func extractIndexedLogs(idx *logindex.Index, blockStart tcommon.JSONUint64, blockEnd tcommon.JSONUint64, addresses []tcommon.Address, topicsFilter [][]tcommon.Hash, filterByAddress bool, result *([]EthGetLogsResult)) error {
+	indexAddresses := []tcommon.Address{}
+	if filterByAddress {
+		indexAddresses = addresses
+	}
+	maxResults := viper.GetInt(common.CfgQueryGetLogsMaxResults)
+
+	return idx.Query(blockStart, blockEnd, indexAddresses, topicsFilter, func(log *logindex.Log) error {
+		if filterByAddress && !addressMatch(addresses, log.Address) {
+			return nil
+		}
+		if !topicsMatch(topicsFilter, &types.Log{Address: log.Address, Topics: log.Topics, Data: log.Data}) {
+			return nil
+		}
+		if maxResults > 0 && len(*result) >= maxResults {
+			return fmt.Errorf("query returned more than %v results, narrow the block range or the filter", maxResults)
+		}
+
+		res := EthGetLogsResult{}
+		res.Type = "mined"
+		res.LogIndex = common.Int2hex2str(log.LogIndex)
+		res.TransactionIndex = common.Int2hex2str(log.TxIndex)
+		res.TransactionHash = log.TxHash
+		res.BlockHash = log.BlockHash
+		res.BlockNumber = hexutil.EncodeUint64(uint64(log.BlockHeight))
+		res.Address = log.Address
+		res.Data = "0x" + hex.EncodeToString(log.Data)
+		res.Topics = log.Topics
+		*result = append(*result, res)
+		return nil
+	})
+}
@@ -4,9 +4,6 @@ import (
 	"context"
 
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
-	trpc "github.com/scripttoken/script/rpc"
-
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 // ------------------------------- eth_getTransactionByBlockNumberAndIndex -----------------------------------
@@ -14,7 +11,9 @@ func (e *EthRPCService) GetTransactionByBlockNumberAndIndex(ctx context.Context,
 	logger.Infof("GetTransactionByBlockNumberAndIndex called")
 	height := common.GetHeightByTag(numberStr)
 	txIndex := common.GetHeightByTag(txIndexStr) //TODO: use common
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-	rpcRes, rpcErr := client.Call("script.GetBlockByHeight", trpc.GetBlockByHeightArgs{Height: height})
-	return GetIndexedTransactionFromBlock(rpcRes, rpcErr, txIndex)
+	block, err := e.backend.GetBlockByHeight(ctx, height)
+	if err != nil {
+		return result, err
+	}
+	return GetIndexedTransactionFromBlock(block, txIndex)
 }
@@ -3,18 +3,16 @@ package ethrpc
 import (
 	"context"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"strings"
-	"time"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
 	tcommon "github.com/scripttoken/script/common"
 	"github.com/scripttoken/script/common/hexutil"
 	"github.com/scripttoken/script/ledger/types"
 
 	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 // ------------------------------- eth_getTransactionByHash -----------------------------------
@@ -22,53 +20,24 @@ func (e *EthRPCService) GetTransactionByHash(ctx context.Context, hashStr string
 	logger.Infof("eth_getTransactionByHash called, txHash: %v", hashStr)
 
 	result = common.EthGetTransactionResult{}
-	var resultIntf interface{}
-	var scriptGetTransactionResult trpc.GetTransactionResult
+	var scriptGetTransactionResult *trpc.GetTransactionResult
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-	maxRetry := 5
-	for i := 0; i < maxRetry; i++ { // It might take some time for a block to be finalized, retry a few times
-		rpcRes, rpcErr := client.Call("script.GetTransaction", trpc.GetTransactionArgs{Hash: hashStr})
-
-		if rpcErr != nil {
-			logger.Warnf("eth_getTransactionByHash failed, err: %v", rpcErr)
-		}
-
-		parse := func(jsonBytes []byte) (interface{}, error) {
-			trpcResult := trpc.GetTransactionResult{}
-			json.Unmarshal(jsonBytes, &trpcResult)
-			if (trpcResult.BlockHash == tcommon.Hash{}) {
-				return trpcResult, nil // Thet tx is not finalized yet. Just let the outer loop retry. Otherwise, tx unmashal might crash if we continue the parsing
-			}
-			var objmap map[string]json.RawMessage
-			json.Unmarshal(jsonBytes, &objmap)
-			if objmap["transaction"] != nil {
-				if types.TxType(trpcResult.Type) == types.TxSend {
-					tx := types.SendTx{}
-					json.Unmarshal(objmap["transaction"], &tx)
-					trpcResult.Tx = &tx
-				}
-				if types.TxType(trpcResult.Type) == types.TxSmartContract {
-					tx := types.SmartContractTx{}
-					json.Unmarshal(objmap["transaction"], &tx)
-					trpcResult.Tx = &tx
-				}
-			}
-			logger.Infof("eth_getTransactionByHash EvmRet: %+v\n", trpcResult.Receipt.EvmRet)
-			return trpcResult, nil
-		}
-		resultIntf, err = common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
+	err = e.retry.Do(ctx, func() (err error) { // It might take some time for a block to be finalized, retry a few times
+		scriptGetTransactionResult, err = e.backend.GetTransaction(ctx, hashStr)
 		if err != nil {
-			logger.Warnf("eth_getTransactionByHash failed, err: %v", err)
-			return result, err
+			return err
 		}
-
-		scriptGetTransactionResult = resultIntf.(trpc.GetTransactionResult)
-		if (scriptGetTransactionResult.BlockHash != tcommon.Hash{}) {
-			break
+		if (scriptGetTransactionResult.BlockHash == tcommon.Hash{}) {
+			return backend.ErrNotFinalized
 		}
-
-		time.Sleep(blockInterval) // one block duration
+		return nil
+	})
+	if err != nil {
+		logger.Warnf("eth_getTransactionByHash failed, err: %v", err)
+		return result, err
+	}
+	if scriptGetTransactionResult.Receipt != nil {
+		logger.Infof("eth_getTransactionByHash EvmRet: %+v\n", scriptGetTransactionResult.Receipt.EvmRet)
 	}
 
 	result.BlockHash = scriptGetTransactionResult.BlockHash
@@ -117,7 +86,7 @@ func (e *EthRPCService) GetTransactionByHash(ctx context.Context, hashStr string
 			GetRSVfromSignature(data, &result)
 		}
 	}
-	result.TransactionIndex, err = GetTransactionIndex(result.BlockHash, nativeTxHash, client)
+	result.TransactionIndex, err = GetTransactionIndex(ctx, e.backend, result.BlockHash, nativeTxHash)
 	if err != nil {
 		return result, err
 	}
@@ -128,23 +97,13 @@ func (e *EthRPCService) GetTransactionByHash(ctx context.Context, hashStr string
 	return result, nil
 }
 
-func GetTransactionIndex(blockHash tcommon.Hash, transactionHash tcommon.Hash, client *rpcc.RPCClient) (hexutil.Uint64, error) {
-	rpcRes, rpcErr := client.Call("script.GetBlock", trpc.GetBlockArgs{Hash: blockHash})
-	if rpcErr != nil {
-		return 0, rpcErr
-	}
-	jsonBytes, err := json.MarshalIndent(rpcRes.Result, "", "    ")
+func GetTransactionIndex(ctx context.Context, b backend.ScriptBackend, blockHash tcommon.Hash, transactionHash tcommon.Hash) (hexutil.Uint64, error) {
+	block, err := b.GetBlock(ctx, blockHash)
 	if err != nil {
 		return 0, err
 	}
-	var objmap map[string]json.RawMessage
-	json.Unmarshal(jsonBytes, &objmap)
-	var txs []common.Tx
-	if objmap["transactions"] != nil {
-		json.Unmarshal(objmap["transactions"], &txs)
-	}
 
-	for i, tx := range txs {
+	for i, tx := range block.Txs {
 		if tx.Hash == transactionHash {
 			return hexutil.Uint64(i), nil
 		}
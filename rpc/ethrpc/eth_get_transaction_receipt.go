@@ -5,73 +5,37 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"time"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
 
 	tcommon "github.com/scripttoken/script/common"
 	"github.com/scripttoken/script/common/hexutil"
 	"github.com/scripttoken/script/ledger/types"
-	"github.com/scripttoken/script/rpc"
 	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
 )
 
 // ------------------------------- eth_getTransactionReceipt -----------------------------------
 func (e *EthRPCService) GetTransactionReceipt(ctx context.Context, hashStr string) (interface{}, error) {
 	logger.Infof("eth_getTransactionReceipt called, txHash: %v", hashStr)
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
 	result := common.EthGetReceiptResult{}
 
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		trpcResult := trpc.GetTransactionResult{}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		var objmap map[string]json.RawMessage
-		json.Unmarshal(jsonBytes, &objmap)
-		if objmap["transaction"] != nil {
-			if types.TxType(trpcResult.Type) == types.TxSend {
-				tx := types.SendTx{}
-				json.Unmarshal(objmap["transaction"], &tx)
-				result.From = tx.Inputs[0].Address
-				result.To = tx.Outputs[0].Address
-			}
-			if types.TxType(trpcResult.Type) == types.TxSmartContract {
-				tx := types.SmartContractTx{}
-				json.Unmarshal(objmap["transaction"], &tx)
-				result.From = tx.From.Address
-				result.To = tx.To.Address
-				result.ContractAddress = trpcResult.Receipt.ContractAddress
-			}
-		}
-		return trpcResult, nil
-	}
-
-	var scriptGetTransactionResult trpc.GetTransactionResult
-	maxRetry := 5
-	for i := 0; i < maxRetry; i++ { // It might take some time for a tx to be finalized, retry a few times
-		rpcRes, rpcErr := client.Call("script.GetTransaction", trpc.GetTransactionArgs{Hash: hashStr})
-		logger.Debugf("eth_getTransactionReceipt called, Script rpcRes: %v, rpcErr: %v", rpcRes, rpcErr)
-
-		resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
+	var scriptGetTransactionResult *trpc.GetTransactionResult
+	err := e.retry.Do(ctx, func() (err error) { // It might take some time for a tx to be finalized, retry a few times
+		scriptGetTransactionResult, err = e.backend.GetTransaction(ctx, hashStr)
 		if err != nil {
-			resultMsg := ""
-			if resultIntf != nil {
-				resultMsg = resultIntf.(string)
-			}
-			logger.Errorf("eth_getTransactionReceipt, err: %v, result: %v", err, resultMsg)
-			return result, err
+			return err
 		}
-
-		scriptGetTransactionResult = resultIntf.(trpc.GetTransactionResult)
-		if scriptGetTransactionResult.Status == rpc.TxStatusFinalized {
-			break
+		if scriptGetTransactionResult.Status != trpc.TxStatusFinalized {
+			logger.Debugf("eth_getTransactionReceipt, tx %v, status: %v", hashStr, scriptGetTransactionResult.Status)
+			return backend.ErrNotFinalized
 		}
-
-		errStr := fmt.Sprintf("eth_getTransactionReceipt, tx %v, status: %v", hashStr, scriptGetTransactionResult.Status)
-		logger.Debugf(errStr)
-
-		time.Sleep(blockInterval) // one block duration
+		return nil
+	})
+	if err != nil && err != backend.ErrNotFinalized {
+		logger.Errorf("eth_getTransactionReceipt, err: %v", err)
+		return result, err
 	}
 
 	logger.Debugf("scriptGetTransactionResult: %v", scriptGetTransactionResult)
@@ -80,6 +44,21 @@ func (e *EthRPCService) GetTransactionReceipt(ctx context.Context, hashStr strin
 		return result, nil
 	}
 
+	if scriptGetTransactionResult.Tx != nil {
+		if types.TxType(scriptGetTransactionResult.Type) == types.TxSend {
+			tx := scriptGetTransactionResult.Tx.(*types.SendTx)
+			result.From = tx.Inputs[0].Address
+			result.To = tx.Outputs[0].Address
+		}
+		if types.TxType(scriptGetTransactionResult.Type) == types.TxSmartContract {
+			tx := scriptGetTransactionResult.Tx.(*types.SmartContractTx)
+			result.From = tx.From.Address
+			result.To = tx.To.Address
+			result.ContractAddress = scriptGetTransactionResult.Receipt.ContractAddress
+			result.EffectiveGasPrice = "0x" + tx.GasPrice.Text(16)
+		}
+	}
+
 	result.BlockHash = scriptGetTransactionResult.BlockHash
 	result.BlockHeight = hexutil.Uint64(scriptGetTransactionResult.BlockHeight)
 	result.TxHash = scriptGetTransactionResult.TxHash
@@ -94,8 +73,7 @@ func (e *EthRPCService) GetTransactionReceipt(ctx context.Context, hashStr strin
 	}
 
 	//TODO: handle logIndex & TransactionIndex of logs
-	var err error
-	result.TransactionIndex, result.CumulativeGasUsed, err = GetTransactionIndexAndCumulativeGasUsed(result.BlockHash, result.TxHash, result.Logs, client)
+	result.TransactionIndex, result.CumulativeGasUsed, err = GetTransactionIndexAndCumulativeGasUsed(ctx, e.backend, result.BlockHash, result.TxHash, result.Logs)
 	if err != nil {
 		logger.Errorf("eth_getTransactionReceipt, err: %v, result: %v", err, result)
 		return nil, err
@@ -106,7 +84,7 @@ func (e *EthRPCService) GetTransactionReceipt(ctx context.Context, hashStr strin
 		result.Status = 0
 	}
 
-	result.LogsBloom = "0x00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000"
+	result.LogsBloom = common.LogsBloom(scriptGetTransactionResult.Receipt.Logs).Hex()
 
 	//logger.Infof("eth_getTransactionReceipt, txHash: %v, result.BlockHash: %v, result.ContractAddress: %v, result.Status: %v", hashStr, result.BlockHash.Hex(), result.ContractAddress.Hex(), result.Status)
 	resultJsonBytes, _ := json.MarshalIndent(result, "", "    ")
@@ -115,25 +93,15 @@ func (e *EthRPCService) GetTransactionReceipt(ctx context.Context, hashStr strin
 	return result, nil
 }
 
-func GetTransactionIndexAndCumulativeGasUsed(blockHash tcommon.Hash, transactionHash tcommon.Hash, logs []common.EthLogObj, client *rpcc.RPCClient) (hexutil.Uint64, hexutil.Uint64, error) {
-	rpcRes, rpcErr := client.Call("script.GetBlock", trpc.GetBlockArgs{Hash: blockHash})
-	if rpcErr != nil {
-		return 0, 0, rpcErr
-	}
-	jsonBytes, err := json.MarshalIndent(rpcRes.Result, "", "    ")
+func GetTransactionIndexAndCumulativeGasUsed(ctx context.Context, b backend.ScriptBackend, blockHash tcommon.Hash, transactionHash tcommon.Hash, logs []common.EthLogObj) (hexutil.Uint64, hexutil.Uint64, error) {
+	block, err := b.GetBlock(ctx, blockHash)
 	if err != nil {
 		return 0, 0, err
 	}
-	var objmap map[string]json.RawMessage
-	json.Unmarshal(jsonBytes, &objmap)
-	var txs []common.Tx
-	if objmap["transactions"] != nil {
-		json.Unmarshal(objmap["transactions"], &txs)
-	}
 	var cumulativeGas hexutil.Uint64
 	var logIndex int
-	for i, tx := range txs {
-		if types.TxType(tx.Type) == types.TxSmartContract {
+	for i, tx := range block.Txs {
+		if types.TxType(tx.Type) == types.TxSmartContract && tx.Receipt != nil {
 			cumulativeGas += hexutil.Uint64(tx.Receipt.GasUsed)
 			if tx.Hash != transactionHash {
 				logIndex += len(tx.Receipt.Logs)
@@ -0,0 +1,47 @@
+package ethrpc
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/gasprice"
+	"github.com/spf13/viper"
+)
+
+// ------------------------------- eth_maxPriorityFeePerGas -----------------------------------
+
+// MaxPriorityFeePerGas suggests a tip, as the median over the recent non-empty blocks of the
+// tip at a percentile of each block. The blocks and the percentile are the ones of the gas price
+// oracle, gasPrice.blocks and gasPrice.percentile, so that the tip agrees with eth_gasPrice.
+func (e *EthRPCService) MaxPriorityFeePerGas(ctx context.Context) (result string, err error) {
+	logger.Infof("eth_maxPriorityFeePerGas called")
+
+	currentHeight, err := backend.GetCurrentHeight(ctx, e.backend)
+	if err != nil {
+		return "", err
+	}
+
+	blocks := viper.GetUint64(common.CfgGasPriceBlocks)
+	percentile := viper.GetFloat64(common.CfgGasPricePercentile)
+	history, err := gasprice.GetFeeHistory(ctx, e.backend, e.retry, blocks, currentHeight, []float64{percentile},
+		getDefaultGasPrice(ctx, e.backend), viper.GetUint64(common.CfgScriptBlockGasLimit))
+	if err != nil {
+		return "", err
+	}
+
+	tips := []*big.Int{}
+	for i, rewards := range history.Rewards {
+		if history.GasUsedRatio[i] > 0 {
+			tips = append(tips, rewards[0])
+		}
+	}
+	if len(tips) == 0 {
+		return "0x0", nil
+	}
+
+	sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+	return "0x" + tips[len(tips)/2].Text(16), nil
+}
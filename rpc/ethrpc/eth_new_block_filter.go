@@ -0,0 +1,23 @@
+package ethrpc
+
+import (
+	"context"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+)
+
+// ------------------------------- eth_newBlockFilter -----------------------------------
+
+// NewBlockFilter installs a filter for the blocks finalized from now on, their hashes are
+// returned by eth_getFilterChanges
+func (e *EthRPCService) NewBlockFilter(ctx context.Context) (erpclib.ID, error) {
+	logger.Infof("eth_newBlockFilter called")
+
+	currentHeight, err := backend.GetCurrentHeight(ctx, e.backend)
+	if err != nil {
+		return "", err
+	}
+
+	return e.filters.install(&filter{typ: blocksFilter, cursor: currentHeight}), nil
+}
@@ -0,0 +1,29 @@
+package ethrpc
+
+import (
+	"context"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+)
+
+// ------------------------------- eth_newFilter -----------------------------------
+
+// NewFilter installs a filter for the logs of the blocks finalized from now on, the
+// matching logs are returned by eth_getFilterChanges
+func (e *EthRPCService) NewFilter(ctx context.Context, args EthGetLogsArgs) (erpclib.ID, error) {
+	logger.Infof("eth_newFilter called, fromBlock: %v, toBlock: %v, address: %v, topics: %v",
+		args.FromBlock, args.ToBlock, args.Address, args.Topics)
+
+	currentHeight, err := backend.GetCurrentHeight(ctx, e.backend)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := newLogsFilter(args, currentHeight)
+	if err != nil {
+		return "", err
+	}
+
+	return e.filters.install(f), nil
+}
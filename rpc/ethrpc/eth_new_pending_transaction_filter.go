@@ -0,0 +1,18 @@
+package ethrpc
+
+import (
+	"context"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	tcommon "github.com/scripttoken/script/common"
+)
+
+// ------------------------------- eth_newPendingTransactionFilter -----------------------------------
+
+// NewPendingTransactionFilter installs a filter for the transactions broadcast through this
+// adaptor from now on, their hashes are returned by eth_getFilterChanges
+func (e *EthRPCService) NewPendingTransactionFilter(ctx context.Context) (erpclib.ID, error) {
+	logger.Infof("eth_newPendingTransactionFilter called")
+
+	return e.filters.install(&filter{typ: pendingTxFilter, txHashes: []tcommon.Hash{}}), nil
+}
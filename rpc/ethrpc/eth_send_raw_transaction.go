@@ -2,12 +2,8 @@ package ethrpc
 
 import (
 	"context"
-	"encoding/json"
 
-	"github.com/scripttoken/script-eth-rpc-adaptor/common"
-
-	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
+	tcommon "github.com/scripttoken/script/common"
 )
 
 // ------------------------------- eth_sendRawTransaction -----------------------------------
@@ -15,23 +11,15 @@ import (
 func (e *EthRPCService) SendRawTransaction(ctx context.Context, txBytes string) (result string, err error) {
 	logger.Infof("eth_sendRawTransaction called")
 
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-	rpcRes, rpcErr := client.Call("script.BroadcastRawEthTransactionAsync", trpc.BroadcastRawTransactionAsyncArgs{TxBytes: txBytes})
-
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		trpcResult := trpc.BroadcastRawTransactionAsyncResult{}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		return trpcResult.TxHash, nil
-	}
-
-	resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
+	result, err = e.backend.BroadcastRawEthTransactionAsync(ctx, txBytes)
 	if err != nil {
 		logger.Errorf("eth_sendRawTransaction, err: %v", err)
 		return "", err
 	}
-	result = resultIntf.(string)
 
 	logger.Infof("eth_sendRawTransaction, result: %v\n", result)
 
+	e.follower.AddPendingTx(tcommon.HexToHash(result))
+
 	return result, nil
 }
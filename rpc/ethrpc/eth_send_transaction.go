@@ -2,11 +2,10 @@ package ethrpc
 
 import (
 	"context"
-	"encoding/json"
 
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
-	trpc "github.com/scripttoken/script/rpc"
-	rpcc "github.com/ybbus/jsonrpc"
+	tcommon "github.com/scripttoken/script/common"
 )
 
 // ------------------------------- eth_sendTransaction -----------------------------------
@@ -24,29 +23,26 @@ func (e *EthRPCService) SendTransaction(ctx context.Context, argObj common.EthSm
 		return "", nil
 	}
 
-	signedTx, err := common.GetSignedBytes(argObj, chainID, blockNumber)
+	sequence, err := backend.GetSeqByAddress(ctx, e.backend, argObj.From)
+	if err != nil {
+		logger.Errorf("eth_sendTransaction, failed to get the sequence of %v: %v", argObj.From.Hex(), err)
+		return "", err
+	}
+	signedTx, err := common.GetSignedBytes(argObj, sequence, chainID, blockNumber)
 	if err != nil {
 		return "", nil
 	}
 	logger.Infof("eth_sendTransaction broadcasting signedTX: %v\n", signedTx)
-	client := rpcc.NewRPCClient(common.GetScriptRPCEndpoint())
-	rpcRes, rpcErr := client.Call("script.BroadcastRawTransactionAsync", trpc.BroadcastRawTransactionAsyncArgs{TxBytes: signedTx})
-
-	parse := func(jsonBytes []byte) (interface{}, error) {
-		trpcResult := trpc.BroadcastRawTransactionAsyncResult{}
-		json.Unmarshal(jsonBytes, &trpcResult)
-		return trpcResult.TxHash, nil
-	}
-
-	resultIntf, err := common.HandleScriptRPCResponse(rpcRes, rpcErr, parse)
+	result, err = e.backend.BroadcastRawTransactionAsync(ctx, signedTx)
 	if err != nil {
-		//logger.Errorf("eth_sendTransaction, err: %v, result: %v", err, resultIntf.(string))
+		//logger.Errorf("eth_sendTransaction, err: %v, result: %v", err, result)
 		return "", err
 	}
-	result = resultIntf.(string)
 
 	logger.Infof("eth_sendTransaction, result: %v", result)
 
+	e.follower.AddPendingTx(tcommon.HexToHash(result))
+
 	return result, nil
 
 }
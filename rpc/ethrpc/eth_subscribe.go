@@ -0,0 +1,161 @@
+package ethrpc
+
+import (
+	"context"
+	"math/big"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	tcommon "github.com/scripttoken/script/common"
+)
+
+// subscriptionBufferSize is the number of events buffered for a subscriber before the follower blocks
+const subscriptionBufferSize = 16
+
+var activeSubscriptions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "adaptor_ws_subscriptions",
+	Help: "Active eth_subscribe subscriptions, by kind.",
+}, []string{"kind"})
+
+// ------------------------------- eth_subscribe -----------------------------------
+
+// NewHeads sends a notification each time a new block is finalized. Reached via eth_subscribe("newHeads").
+func (e *EthRPCService) NewHeads(ctx context.Context) (*erpclib.Subscription, error) {
+	logger.Infof("eth_subscribe called, newHeads")
+
+	notifier, supported := erpclib.NotifierFromContext(ctx)
+	if !supported {
+		return &erpclib.Subscription{}, erpclib.ErrNotificationsUnsupported
+	}
+
+	ethChainID, err := backend.GetEthChainID(ctx, e.backend)
+	if err != nil {
+		return nil, err
+	}
+	chainID := new(big.Int).SetUint64(ethChainID)
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		activeSubscriptions.WithLabelValues("newHeads").Inc()
+		defer activeSubscriptions.WithLabelValues("newHeads").Dec()
+
+		blocks := make(chan *common.ScriptGetBlockResultInner, subscriptionBufferSize)
+		blocksSub := e.follower.SubscribeNewBlocks(blocks)
+		defer blocksSub.Unsubscribe()
+
+		for {
+			select {
+			case block := <-blocks:
+				header, err := e.getEthBlock(chainID, block, false)
+				if err != nil {
+					logger.Warnf("eth_subscribe, failed to convert block %v: %v", block.Height, err)
+					continue
+				}
+				notifier.Notify(rpcSub.ID, header)
+			case err := <-blocksSub.Err():
+				logger.Warnf("eth_subscribe, newHeads subscription %v stopped: %v", rpcSub.ID, err)
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Logs sends a notification for each log matching the address and topics of the filter in the
+// newly finalized blocks. The block range of the filter is ignored. Reached via eth_subscribe("logs", filter).
+func (e *EthRPCService) Logs(ctx context.Context, args EthGetLogsArgs) (*erpclib.Subscription, error) {
+	logger.Infof("eth_subscribe called, logs, address: %v, topics: %v", args.Address, args.Topics)
+
+	notifier, supported := erpclib.NotifierFromContext(ctx)
+	if !supported {
+		return &erpclib.Subscription{}, erpclib.ErrNotificationsUnsupported
+	}
+
+	addresses, err := parseAddresses(args.Address)
+	if err != nil {
+		return nil, err
+	}
+	topicsFilter, err := parseTopicsFilter(args.Topics)
+	if err != nil {
+		return nil, err
+	}
+	filterByAddress := shouldFilterByAddress(addresses)
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		activeSubscriptions.WithLabelValues("logs").Inc()
+		defer activeSubscriptions.WithLabelValues("logs").Dec()
+
+		blocks := make(chan *common.ScriptGetBlockResultInner, subscriptionBufferSize)
+		blocksSub := e.follower.SubscribeNewBlocks(blocks)
+		defer blocksSub.Unsubscribe()
+
+		for {
+			select {
+			case block := <-blocks:
+				logs := []EthGetLogsResult{}
+				extractLogs(addresses, topicsFilter, filterByAddress, []*common.ScriptGetBlockResultInner{block}, &logs)
+				for _, log := range logs {
+					notifier.Notify(rpcSub.ID, log)
+				}
+			case err := <-blocksSub.Err():
+				logger.Warnf("eth_subscribe, logs subscription %v stopped: %v", rpcSub.ID, err)
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// NewPendingTransactions sends a notification with the hash of each transaction broadcast through
+// this adaptor. Reached via eth_subscribe("newPendingTransactions").
+func (e *EthRPCService) NewPendingTransactions(ctx context.Context) (*erpclib.Subscription, error) {
+	logger.Infof("eth_subscribe called, newPendingTransactions")
+
+	notifier, supported := erpclib.NotifierFromContext(ctx)
+	if !supported {
+		return &erpclib.Subscription{}, erpclib.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		activeSubscriptions.WithLabelValues("newPendingTransactions").Inc()
+		defer activeSubscriptions.WithLabelValues("newPendingTransactions").Dec()
+
+		txHashes := make(chan tcommon.Hash, subscriptionBufferSize)
+		txHashesSub := e.follower.SubscribePendingTxs(txHashes)
+		defer txHashesSub.Unsubscribe()
+
+		for {
+			select {
+			case txHash := <-txHashes:
+				notifier.Notify(rpcSub.ID, txHash)
+			case err := <-txHashesSub.Err():
+				logger.Warnf("eth_subscribe, newPendingTransactions subscription %v stopped: %v", rpcSub.ID, err)
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
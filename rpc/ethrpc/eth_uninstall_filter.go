@@ -0,0 +1,15 @@
+package ethrpc
+
+import (
+	"context"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+)
+
+// ------------------------------- eth_uninstallFilter -----------------------------------
+
+func (e *EthRPCService) UninstallFilter(ctx context.Context, id erpclib.ID) (bool, error) {
+	logger.Infof("eth_uninstallFilter called, id: %v", id)
+
+	return e.filters.uninstall(id), nil
+}
@@ -0,0 +1,199 @@
+package ethrpc
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/follower"
+	tcommon "github.com/scripttoken/script/common"
+	"github.com/spf13/viper"
+)
+
+var errFilterNotFound = errors.New("filter not found")
+
+type filterType byte
+
+const (
+	logsFilter filterType = iota
+	blocksFilter
+	pendingTxFilter
+)
+
+// defaultFilterTimeout is used when filter.timeoutSecs is not a positive value
+const defaultFilterTimeout = 5 * time.Minute
+
+// maxPendingTxHashes caps the number of tx hashes kept for a pending tx filter between two polls
+const maxPendingTxHashes = 4096
+
+// filter is an installed filter. Its cursor is the last finalized height whose changes have
+// been returned to the client.
+type filter struct {
+	typ      filterType
+	lastUsed time.Time
+	cursor   tcommon.JSONUint64
+
+	// logs filter
+	args            EthGetLogsArgs
+	addresses       []tcommon.Address
+	topicsFilter    [][]tcommon.Hash
+	filterByAddress bool
+	toBlock         tcommon.JSONUint64
+
+	// pending tx filter
+	txHashes []tcommon.Hash
+}
+
+// filterManager keeps track of the installed filters and uninstalls the ones which have not
+// been polled for a while.
+type filterManager struct {
+	mu      sync.Mutex
+	filters map[erpclib.ID]*filter
+	timeout time.Duration
+}
+
+func newFilterManager(f *follower.Follower) *filterManager {
+	m := &filterManager{
+		filters: make(map[erpclib.ID]*filter),
+		timeout: time.Duration(viper.GetInt64(common.CfgFilterTimeoutSecs)) * time.Second,
+	}
+	if m.timeout <= 0 {
+		m.timeout = defaultFilterTimeout
+	}
+	go m.eventLoop(f)
+	return m
+}
+
+func (m *filterManager) install(f *filter) erpclib.ID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := erpclib.NewID()
+	f.lastUsed = time.Now()
+	m.filters[id] = f
+	return id
+}
+
+func (m *filterManager) uninstall(id erpclib.ID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, found := m.filters[id]
+	delete(m.filters, id)
+	return found
+}
+
+// get returns a copy of the filter, and marks it as used
+func (m *filterManager) get(id erpclib.ID) (filter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, found := m.filters[id]
+	if !found {
+		return filter{}, errFilterNotFound
+	}
+	f.lastUsed = time.Now()
+	return *f, nil
+}
+
+// advance moves the cursor of the filter forward once its changes have been returned
+func (m *filterManager) advance(id erpclib.ID, cursor tcommon.JSONUint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, found := m.filters[id]; found && cursor > f.cursor {
+		f.cursor = cursor
+	}
+}
+
+// takeTxHashes returns and clears the tx hashes collected for a pending tx filter
+func (m *filterManager) takeTxHashes(id erpclib.ID) ([]tcommon.Hash, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, found := m.filters[id]
+	if !found {
+		return nil, errFilterNotFound
+	}
+	f.lastUsed = time.Now()
+	txHashes := f.txHashes
+	f.txHashes = []tcommon.Hash{}
+	return txHashes, nil
+}
+
+func (m *filterManager) eventLoop(f *follower.Follower) {
+	txHashes := make(chan tcommon.Hash, subscriptionBufferSize)
+	txHashesSub := f.SubscribePendingTxs(txHashes)
+	defer func() { txHashesSub.Unsubscribe() }()
+
+	ticker := time.NewTicker(m.timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case txHash := <-txHashes:
+			m.addPendingTx(txHash)
+		case <-ticker.C:
+			m.expire()
+		case err := <-txHashesSub.Err():
+			if err == nil {
+				return
+			}
+			logger.Warnf("Pending tx filters fell behind, some tx hashes are missing: %v", err)
+			txHashesSub = f.SubscribePendingTxs(txHashes)
+		}
+	}
+}
+
+func (m *filterManager) addPendingTx(txHash tcommon.Hash) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range m.filters {
+		if f.typ == pendingTxFilter && len(f.txHashes) < maxPendingTxHashes {
+			f.txHashes = append(f.txHashes, txHash)
+		}
+	}
+}
+
+func (m *filterManager) expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, f := range m.filters {
+		if time.Since(f.lastUsed) > m.timeout {
+			logger.Debugf("Filter %v expired", id)
+			delete(m.filters, id)
+		}
+	}
+}
+
+// newLogsFilter parses the criteria of a logs filter, the changes are returned from the given height on
+func newLogsFilter(args EthGetLogsArgs, currentHeight tcommon.JSONUint64) (*filter, error) {
+	addresses, err := parseAddresses(args.Address)
+	if err != nil {
+		return nil, err
+	}
+	topicsFilter, err := parseTopicsFilter(args.Topics)
+	if err != nil {
+		return nil, err
+	}
+
+	toBlock := tcommon.JSONUint64(math.MaxUint64)
+	if args.ToBlock != "" {
+		toBlock = common.GetHeightByTag(args.ToBlock)
+	}
+
+	return &filter{
+		typ:             logsFilter,
+		cursor:          currentHeight,
+		args:            args,
+		addresses:       addresses,
+		topicsFilter:    topicsFilter,
+		filterByAddress: shouldFilterByAddress(addresses),
+		toBlock:         toBlock,
+	}, nil
+}
@@ -1,30 +1,54 @@
 package ethrpc
 
 import (
-	"time"
-
 	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
+	"github.com/scripttoken/script-eth-rpc-adaptor/cache"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/follower"
+	"github.com/scripttoken/script-eth-rpc-adaptor/gasprice"
+	"github.com/scripttoken/script-eth-rpc-adaptor/logindex"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
-var blockInterval time.Duration = 6 * time.Second
-
 var logger *log.Entry = log.WithFields(log.Fields{"prefix": "ethrpc"})
 
 // EthRPCService provides an API to access to the Eth endpoints.
 type EthRPCService struct {
+	backend  backend.ScriptBackend
+	retry    backend.RetryPolicy
+	follower *follower.Follower
+	filters  *filterManager
+	logIndex *logindex.Index // nil if the log index is disabled
+
+	gasOracle *gasprice.Oracle
+
+	ethBlocks *cache.LRU // finalized blocks converted by GetBlockFromTRPCResult
 }
 
 // NewEthRPCService creates a new API for the Ethereum RPC interface
-func NewEthRPCService(namespace string) erpclib.API {
+func NewEthRPCService(namespace string, b backend.ScriptBackend, f *follower.Follower, idx *logindex.Index, oracle *gasprice.Oracle) erpclib.API {
 	if namespace == "" {
 		namespace = "eth"
 	}
 
+	service := &EthRPCService{
+		backend:  b,
+		retry:    backend.RetryPolicyFromConfig(),
+		follower: f,
+		filters:  newFilterManager(f),
+		logIndex: idx,
+
+		gasOracle: oracle,
+
+		ethBlocks: cache.NewLRU("eth_blocks", viper.GetInt64(common.CfgCacheEthBlocksMB)<<20),
+	}
+
 	return erpclib.API{
 		Namespace: namespace,
 		Version:   "1.0",
-		Service:   &EthRPCService{},
+		Service:   service,
 		Public:    true,
 	}
 }
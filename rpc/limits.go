@@ -0,0 +1,343 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/tracing"
+	"github.com/spf13/viper"
+)
+
+const (
+	errCodeInvalidRequest   = -32600
+	errCodeMethodNotFound   = -32601
+	errCodeResponseTooLarge = -32003
+
+	errMsgBatchTooLarge    = "batch too large"
+	errMsgResponseTooLarge = "response too large"
+
+	// maxRequestContentLength is the request size accepted by go-ethereum's RPC server
+	maxRequestContentLength = 5 * 1024 * 1024
+)
+
+var nullID = json.RawMessage("null")
+
+type jsonrpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type jsonrpcErrorResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   jsonrpcError    `json:"error"`
+}
+
+type jsonrpcHeader struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+// callLimits bounds the work a single connection can put on the adaptor and the Script node
+type callLimits struct {
+	batchRequestLimit  int
+	maxResponseBytes   int
+	maxConcurrentCalls int
+}
+
+func newCallLimits() callLimits {
+	limits := callLimits{
+		batchRequestLimit:  viper.GetInt(common.CfgRPCBatchRequestLimit),
+		maxResponseBytes:   viper.GetInt(common.CfgRPCMaxResponseBytes),
+		maxConcurrentCalls: viper.GetInt(common.CfgRPCMaxConcurrentCallsPerConn),
+	}
+	if limits.maxConcurrentCalls <= 0 {
+		limits.maxConcurrentCalls = 1
+	}
+	return limits
+}
+
+func errorResponse(id json.RawMessage, code int, message string) json.RawMessage {
+	return errorResponseWithData(id, code, message, nil)
+}
+
+func errorResponseWithData(id json.RawMessage, code int, message string, data interface{}) json.RawMessage {
+	if len(id) == 0 {
+		id = nullID
+	}
+	res, _ := json.Marshal(&jsonrpcErrorResponse{
+		Version: "2.0",
+		ID:      id,
+		Error:   jsonrpcError{Code: code, Message: message, Data: data},
+	})
+	return res
+}
+
+// withErrorData adds the data member recorded by the handler of the call, see
+// common.RecordErrorData, to the error of its response
+func withErrorData(ctx context.Context, res json.RawMessage) json.RawMessage {
+	data := common.ErrorData(ctx)
+	if data == nil {
+		return res
+	}
+	obj := jsonrpcErrorResponse{}
+	if err := json.Unmarshal(res, &obj); err != nil || obj.Error.Code == 0 || obj.Error.Data != nil {
+		return res
+	}
+	return errorResponseWithData(obj.ID, obj.Error.Code, obj.Error.Message, data)
+}
+
+// parseBatch splits a batch into its messages, isBatch is false for a single message
+func parseBatch(data []byte) (msgs []json.RawMessage, isBatch bool) {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 || data[0] != '[' {
+		return nil, false
+	}
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, false // let the RPC server report the parse error
+	}
+	return msgs, true
+}
+
+// messageID returns the id of a request or a response, nil for a notification
+func messageID(msg json.RawMessage) json.RawMessage {
+	obj := messageHeader(msg)
+	if len(obj.ID) == 0 || bytes.Equal(obj.ID, nullID) {
+		return nil
+	}
+	return obj.ID
+}
+
+// messageHeader returns the id and the method of a request
+func messageHeader(msg json.RawMessage) jsonrpcHeader {
+	obj := jsonrpcHeader{}
+	json.Unmarshal(msg, &obj)
+	return obj
+}
+
+// callGate decides whether a call read from the given http request, or from the websocket
+// connection upgraded from it, is served. It returns the error response of the rejected calls.
+type callGate func(r *http.Request, msg json.RawMessage) (json.RawMessage, bool)
+
+// chainGates rejects the calls rejected by any of the gates, the nil ones are skipped
+func chainGates(gates ...callGate) callGate {
+	return func(r *http.Request, msg json.RawMessage) (json.RawMessage, bool) {
+		for _, gate := range gates {
+			if gate == nil {
+				continue
+			}
+			if res, rejected := gate(r, msg); rejected {
+				return res, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// methodSet is a set of method names such as eth_sign
+type methodSet map[string]bool
+
+func newMethodSet(methods []string) methodSet {
+	set := make(methodSet)
+	for _, method := range methods {
+		set[method] = true
+	}
+	return set
+}
+
+// rejects is a callGate rejecting the calls to the methods of the set
+func (s methodSet) rejects(r *http.Request, msg json.RawMessage) (json.RawMessage, bool) {
+	if len(s) == 0 {
+		return nil, false
+	}
+	obj := messageHeader(msg)
+	if !s[obj.Method] {
+		return nil, false
+	}
+	return errorResponse(obj.ID, errCodeMethodNotFound, fmt.Sprintf("the method %v does not exist/is not available", obj.Method)), true
+}
+
+// limitResponses replaces the responses past the response size limit with errors
+func (l callLimits) limitResponses(responses []json.RawMessage) []json.RawMessage {
+	if l.maxResponseBytes <= 0 {
+		return responses
+	}
+	size := 0
+	for i, res := range responses {
+		size += len(res)
+		if size > l.maxResponseBytes {
+			responses[i] = errorResponse(messageID(res), errCodeResponseTooLarge, errMsgResponseTooLarge)
+		}
+	}
+	return responses
+}
+
+// httpLimitHandler enforces the call limits in front of the RPC server, and answers the calls
+// rejected by the gate. The calls of a batch are served by a pool of maxConcurrentCalls workers,
+// instead of one after the other by the RPC server.
+type httpLimitHandler struct {
+	limits callLimits
+	gate   callGate
+	next   http.Handler
+}
+
+func newHTTPLimitHandler(next http.Handler, limits callLimits, gate callGate) http.Handler {
+	return &httpLimitHandler{limits: limits, gate: chainGates(gate), next: next}
+}
+
+func (h *httpLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestContentLength+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxRequestContentLength {
+		err := fmt.Errorf("content length too large (%d>%d)", len(body), maxRequestContentLength)
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	msgs, isBatch := parseBatch(body)
+	// The calls continue the trace of the client, if it sent a traceparent header
+	traceCtx := tracing.Extract(r.Context(), r.Header)
+
+	if !isBatch || len(msgs) == 0 {
+		ctx, call := startCall(traceCtx, body, "http")
+		if res, rejected := h.gate(r, body); rejected {
+			call.finish(res)
+			writeJSON(w, res)
+			return
+		}
+		ctx = common.ContextWithErrorData(ctx)
+		rec := h.forward(r.WithContext(ctx), body)
+		resBytes := rec.body.Bytes()
+		if rec.status == http.StatusOK && len(resBytes) > 0 {
+			resBytes = withErrorData(ctx, resBytes)
+			resBytes = h.limits.limitResponses([]json.RawMessage{resBytes})[0]
+		}
+		call.finish(resBytes)
+		rec.writeTo(w, resBytes)
+		return
+	}
+
+	if h.limits.batchRequestLimit > 0 && len(msgs) > h.limits.batchRequestLimit {
+		logger.Debugf("Rejected a batch of %v requests from %v", len(msgs), r.RemoteAddr)
+		writeJSON(w, errorResponse(nullID, errCodeInvalidRequest, errMsgBatchTooLarge))
+		return
+	}
+
+	results := make([]json.RawMessage, len(msgs))
+	slots := make(chan struct{}, h.limits.maxConcurrentCalls)
+	wg := &sync.WaitGroup{}
+	for i, msg := range msgs {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, msg json.RawMessage) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			ctx, call := startCall(traceCtx, msg, "http")
+			defer func() { call.finish(results[i]) }()
+
+			if res, rejected := h.gate(r, msg); rejected {
+				results[i] = res
+				return
+			}
+			ctx = common.ContextWithErrorData(ctx)
+			rec := h.forward(r.WithContext(ctx), msg)
+			if rec.status != http.StatusOK {
+				results[i] = errorResponse(messageID(msg), errCodeInvalidRequest, strings.TrimSpace(rec.body.String()))
+				return
+			}
+			results[i] = withErrorData(ctx, bytes.TrimSpace(rec.body.Bytes()))
+		}(i, msg)
+	}
+	wg.Wait()
+
+	responses := make([]json.RawMessage, 0, len(results))
+	for _, res := range results {
+		if len(res) > 0 { // no response to the notifications
+			responses = append(responses, res)
+		}
+	}
+	responses = h.limits.limitResponses(responses)
+	logger.Debugf("Served a batch of %v requests from %v", len(msgs), r.RemoteAddr)
+
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	batchBytes, err := json.Marshal(responses)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, batchBytes)
+}
+
+// forward serves a single message through the RPC server
+func (h *httpLimitHandler) forward(r *http.Request, body []byte) *responseRecorder {
+	req := r.Clone(r.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	rec := newResponseRecorder()
+	h.next.ServeHTTP(rec, req)
+	return rec
+}
+
+func writeJSON(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// responseRecorder buffers the response of the RPC server so that it can be checked
+// against the limits before it is sent
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	return rec.body.Write(data)
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+}
+
+// writeTo sends the recorded headers and status with the given body
+func (rec *responseRecorder) writeTo(w http.ResponseWriter, body []byte) {
+	for key, values := range rec.header {
+		w.Header()[key] = values
+	}
+	w.Header().Del("Content-Length")
+	w.WriteHeader(rec.status)
+	w.Write(body)
+}
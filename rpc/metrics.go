@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/health"
+	"github.com/scripttoken/script-eth-rpc-adaptor/tracing"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unknownMethod labels the calls to methods which are not registered, so that clients cannot
+// create series at will
+const unknownMethod = "unknown"
+
+var subscriptionType = reflect.TypeOf(&erpclib.Subscription{})
+
+var (
+	// registeredMethods are the methods of the APIs, the only ones the calls are labeled with
+	registeredMethods = methodSet{"rpc_modules": true}
+
+	rpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "adaptor_rpc_requests_total",
+		Help: "Calls served, by method.",
+	}, []string{"method"})
+	rpcErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "adaptor_rpc_errors_total",
+		Help: "Calls answered with an error, by method and JSON-RPC error code.",
+	}, []string{"method", "code"})
+	rpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "adaptor_rpc_request_duration_seconds",
+		Help:    "Latency of the calls, by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	rpcInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "adaptor_rpc_requests_in_flight",
+		Help: "Calls being served.",
+	})
+	wsConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "adaptor_ws_connections",
+		Help: "Open WS connections.",
+	})
+
+	metricsServer   *http.Server
+	metricsEndpoint = ""
+)
+
+// inFlightCall measures and traces a call from the moment it is read until it is answered
+type inFlightCall struct {
+	method string
+	start  time.Time
+	span   trace.Span
+}
+
+// startCall starts the span of the call as a child of the span carried by ctx, if any, and
+// returns the context carrying it
+func startCall(ctx context.Context, msg json.RawMessage, transport string) (context.Context, *inFlightCall) {
+	rpcInFlight.Inc()
+	method := messageHeader(msg).Method
+	ctx, span := tracing.StartSpan(ctx, method, trace.SpanKindServer,
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("rpc.method", method),
+		attribute.String("rpc.transport", transport))
+	return ctx, &inFlightCall{method: method, start: time.Now(), span: span}
+}
+
+// finish records the call with its response, which is empty for a notification
+func (c *inFlightCall) finish(res json.RawMessage) {
+	rpcInFlight.Dec()
+	defer c.span.End()
+
+	method := c.method
+	if !registeredMethods[method] {
+		method = unknownMethod
+	}
+	rpcErr := responseError(res)
+	rpcRequests.WithLabelValues(method).Inc()
+	rpcDuration.WithLabelValues(method).Observe(time.Since(c.start).Seconds())
+	if rpcErr != nil {
+		rpcErrors.WithLabelValues(method, strconv.Itoa(rpcErr.Code)).Inc()
+		c.span.SetAttributes(attribute.Int("rpc.jsonrpc.error_code", rpcErr.Code))
+		tracing.SetError(c.span, errors.New(rpcErr.Message))
+	}
+}
+
+// registerMethods adds the methods of the APIs to registeredMethods, named as the RPC server
+// names them. The subscriptions are reached through <namespace>_subscribe.
+func registerMethods(apis []erpclib.API) {
+	for _, api := range apis {
+		registeredMethods[api.Namespace+"_subscribe"] = true
+		registeredMethods[api.Namespace+"_unsubscribe"] = true
+
+		typ := reflect.TypeOf(api.Service)
+		for i := 0; i < typ.NumMethod(); i++ {
+			method := typ.Method(i)
+			if method.Type.NumOut() > 0 && method.Type.Out(0) == subscriptionType {
+				continue
+			}
+			name := []rune(method.Name)
+			name[0] = unicode.ToLower(name[0])
+			registeredMethods[api.Namespace+"_"+string(name)] = true
+		}
+	}
+}
+
+// abandon drops a call whose connection closed before it was answered
+func (c *inFlightCall) abandon() {
+	rpcInFlight.Dec()
+	tracing.SetError(c.span, errConnClosed)
+	c.span.End()
+}
+
+// responseError returns the error of a response, nil for a result
+func responseError(res json.RawMessage) *jsonrpcError {
+	// Only the responses mentioning an error are decoded, the results can be megabytes long
+	if !bytes.Contains(res, []byte(`"error"`)) {
+		return nil
+	}
+	obj := struct {
+		Error *jsonrpcError `json:"error"`
+	}{}
+	if err := json.Unmarshal(res, &obj); err != nil {
+		return nil
+	}
+	return obj.Error
+}
+
+// startMetrics serves the metrics at /metrics, and the health endpoints, if metrics.enabled is set
+func startMetrics(monitor *health.Monitor) error {
+	if !viper.GetBool(common.CfgMetricsEnabled) {
+		return nil
+	}
+
+	metricsEndpoint = fmt.Sprintf("%v:%v", viper.GetString(common.CfgMetricsAddress), viper.GetString(common.CfgMetricsPort))
+	listener, err := net.Listen("tcp", metricsEndpoint)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	metricsServer = &http.Server{
+		Handler:      monitor.Handler(mux),
+		ReadTimeout:  httpTimeouts.ReadTimeout,
+		WriteTimeout: httpTimeouts.WriteTimeout,
+		IdleTimeout:  httpTimeouts.IdleTimeout,
+	}
+	go metricsServer.Serve(listener)
+
+	logger.Infof("Started metrics server at: %v\n", metricsEndpoint)
+	return nil
+}
+
+func stopMetrics(ctx context.Context) {
+	if metricsServer == nil {
+		return
+	}
+	metricsServer.Shutdown(ctx)
+	metricsServer = nil
+	logger.Infof("Metrics endpoint closed")
+}
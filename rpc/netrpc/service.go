@@ -2,6 +2,7 @@ package netrpc
 
 import (
 	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -9,10 +10,11 @@ var logger *log.Entry = log.WithFields(log.Fields{"prefix": "netrpc"})
 
 // NetRPCService provides an API to access to the Net endpoints.
 type NetRPCService struct {
+	backend backend.ScriptBackend
 }
 
 // NewNetRPCService creates a new API for the Netereum RPC interface
-func NewNetRPCService(namespace string) erpclib.API {
+func NewNetRPCService(namespace string, b backend.ScriptBackend) erpclib.API {
 	if namespace == "" {
 		namespace = "net"
 	}
@@ -20,7 +22,7 @@ func NewNetRPCService(namespace string) erpclib.API {
 	return erpclib.API{
 		Namespace: namespace,
 		Version:   "1.0",
-		Service:   &NetRPCService{},
+		Service:   &NetRPCService{backend: b},
 		Public:    true,
 	}
 }
@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scripttoken/script-eth-rpc-adaptor/apikey"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/ratelimit"
+	"github.com/spf13/viper"
+)
+
+const (
+	errCodeLimitExceeded = -32005
+
+	// apiKeyHeader is the http header carrying the API key of the client
+	apiKeyHeader = "X-API-Key"
+)
+
+// retryHint is the data of the rate limit errors
+type retryHint struct {
+	RetryAfterMillis int64 `json:"retryAfterMillis"`
+}
+
+// methodCosts are the compute units of the methods, keyed by the lower-cased method name
+type methodCosts map[string]float64
+
+func loadMethodCosts() methodCosts {
+	costs := make(methodCosts)
+	for method := range viper.GetStringMap(common.CfgRateLimitMethodCosts) {
+		costs[strings.ToLower(method)] = viper.GetFloat64(common.CfgRateLimitMethodCosts + "." + method)
+	}
+	return costs
+}
+
+// cost returns the compute units of the method, 1 unless configured otherwise
+func (c methodCosts) cost(method string) float64 {
+	if cost, found := c[strings.ToLower(method)]; found {
+		return cost
+	}
+	return 1
+}
+
+// rateLimiter charges the calls to the quota of the API key of the client if the store knows
+// it, otherwise to the quota of its IP, so that made-up keys do not get a fresh quota each.
+// The expensive methods are charged more than one call. The keys of the store with their own
+// quota are charged to it instead of the default one.
+type rateLimiter struct {
+	ips               *ratelimit.Limiter // nil if rate limiting is disabled
+	apiKeys           *ratelimit.Limiter
+	keys              *apikey.Store // nil if the API keys are disabled
+	costs             methodCosts
+	trustForwardedFor bool
+}
+
+// newRateLimiter returns nil if rate limiting and the API keys are both disabled. With rate
+// limiting disabled, only the keys of the store with their own quota are throttled.
+func newRateLimiter(keys *apikey.Store, costs methodCosts) *rateLimiter {
+	enabled := viper.GetBool(common.CfgRateLimitEnabled)
+	if !enabled && keys == nil {
+		return nil
+	}
+
+	l := &rateLimiter{
+		apiKeys:           ratelimit.NewLimiter(0, 0), // only the keys with their own quota are throttled
+		keys:              keys,
+		costs:             costs,
+		trustForwardedFor: viper.GetBool(common.CfgRateLimitTrustForwardedFor),
+	}
+	if enabled {
+		l.ips = ratelimit.NewLimiter(
+			viper.GetFloat64(common.CfgRateLimitIPRequestsPerSec),
+			viper.GetFloat64(common.CfgRateLimitIPBurst))
+		l.apiKeys = ratelimit.NewLimiter(
+			viper.GetFloat64(common.CfgRateLimitAPIKeyRequestsPerSec),
+			viper.GetFloat64(common.CfgRateLimitAPIKeyBurst))
+	}
+	return l
+}
+
+// gate is a callGate rejecting the calls beyond the quota of the client, it returns nil if the limiter is nil
+func (l *rateLimiter) gate() callGate {
+	if l == nil {
+		return nil
+	}
+	return l.take
+}
+
+func (l *rateLimiter) take(r *http.Request, msg json.RawMessage) (json.RawMessage, bool) {
+	obj := messageHeader(msg)
+	cost := l.costs.cost(obj.Method)
+
+	var ok bool
+	var retryAfter time.Duration
+	key := l.clientIP(r)
+	if k, found := l.keys.Lookup(requestAPIKey(r)); found {
+		key = k.Name
+		if k.RequestsPerSec > 0 {
+			ok, retryAfter = l.apiKeys.TakeWithLimit(key, cost, k.RequestsPerSec, k.Burst)
+		} else {
+			ok, retryAfter = l.apiKeys.Take(key, cost)
+		}
+		if !ok {
+			l.keys.RecordRejected(key)
+		}
+	} else {
+		ok, retryAfter = l.ips.Take(key, cost)
+	}
+	if ok {
+		return nil, false
+	}
+	retryAfter = retryAfter.Round(time.Millisecond)
+	logger.Debugf("Rate limited %v calling %v, retry in %v", key, obj.Method, retryAfter)
+	return errorResponseWithData(obj.ID, errCodeLimitExceeded, fmt.Sprintf("rate limit exceeded, retry in %v", retryAfter),
+		&retryHint{RetryAfterMillis: retryAfter.Milliseconds()}), true
+}
+
+func (l *rateLimiter) clientIP(r *http.Request) string {
+	if l.trustForwardedFor {
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			return strings.TrimSpace(strings.Split(forwardedFor, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -1,13 +1,23 @@
 package rpc
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"net/http"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/scripttoken/script-eth-rpc-adaptor/apikey"
+	"github.com/scripttoken/script-eth-rpc-adaptor/backend"
 	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/follower"
+	"github.com/scripttoken/script-eth-rpc-adaptor/gasprice"
+	"github.com/scripttoken/script-eth-rpc-adaptor/health"
+	"github.com/scripttoken/script-eth-rpc-adaptor/logindex"
+	"github.com/scripttoken/script-eth-rpc-adaptor/rpc/debugrpc"
 	"github.com/scripttoken/script-eth-rpc-adaptor/rpc/ethrpc"
 	"github.com/scripttoken/script-eth-rpc-adaptor/rpc/netrpc"
 	"github.com/scripttoken/script-eth-rpc-adaptor/rpc/web3rpc"
@@ -17,19 +27,22 @@ import (
 var logger *log.Entry = log.WithFields(log.Fields{"prefix": "rpc"})
 
 const (
-	netNamespace  = "net"
-	ethNamespace  = "eth"
-	web3Namespace = "web3"
-	evmNamespace  = "evm"
+	netNamespace   = "net"
+	ethNamespace   = "eth"
+	web3Namespace  = "web3"
+	evmNamespace   = "evm"
+	debugNamespace = "debug"
 )
 
 var (
+	// HTTPModules and WSModules are the exposed namespaces, set from rpc.httpModules and rpc.wsModules.
+	// The debug namespace is left out by default, it is served by the authenticated RPC service.
 	HTTPModules = []string{netNamespace, ethNamespace, web3Namespace, evmNamespace}
 	WSModules   = []string{netNamespace, ethNamespace, web3Namespace, evmNamespace}
 
-	httpListener     net.Listener
+	httpServer       *http.Server
 	httpHandler      *erpclib.Server
-	wsListener       net.Listener
+	wsServer         *http.Server
 	wsHandler        *erpclib.Server
 	httpEndpoint     = ""
 	wsEndpoint       = ""
@@ -37,6 +50,11 @@ var (
 	httpTimeouts     = erpclib.DefaultHTTPTimeouts
 	httpOrigins      = []string{"*"}
 	wsOrigins        = []string{"*"}
+	connSlots        chan struct{} // shared by the http & ws listeners, nil if the connections are not limited
+	requestTimeout   time.Duration
+	wsPingInterval   time.Duration
+	privateMethods   methodSet // not served by the public http & ws servers
+	publicGate       callGate  // checks the calls to the public http & ws servers
 )
 
 // Version of the RPC
@@ -47,15 +65,46 @@ func (n Version) Namespace() string {
 	return HTTPModules[n]
 }
 
-// StartServers starts the http & ws servers
-func StartServers(apis []erpclib.API) error {
-	apis = append(apis, getAPIs()...)
+// StartServers starts the http & ws servers if rpc.enabled is set, the authenticated server if
+// authrpc.enabled is set, and the metrics server if metrics.enabled is set. The handlers talk to
+// the Script node through the given backend and receive the new blocks from the given follower.
+// The log index is optional, and so is the API key store, which is only checked on the public
+// http & ws servers. The health endpoints of the monitor are served by the public http server and
+// the metrics server.
+func StartServers(b backend.ScriptBackend, f *follower.Follower, idx *logindex.Index, oracle *gasprice.Oracle, keys *apikey.Store, monitor *health.Monitor, apis []erpclib.API) error {
+	apis = append(apis, getAPIs(b, f, idx, oracle)...)
+	registerMethods(apis)
+
+	if maxConnections := viper.GetInt(common.CfgRPCMaxConnections); maxConnections > 0 {
+		connSlots = make(chan struct{}, maxConnections)
+	}
+	requestTimeout = time.Duration(viper.GetInt64(common.CfgRPCTimeoutSecs)) * time.Second
+	httpTimeouts = erpclib.HTTPTimeouts{
+		ReadTimeout:  time.Duration(viper.GetInt64(common.CfgRPCReadTimeoutSecs)) * time.Second,
+		WriteTimeout: requestTimeout + writeTimeoutMargin,
+		IdleTimeout:  time.Duration(viper.GetInt64(common.CfgRPCIdleTimeoutSecs)) * time.Second,
+	}
+	wsPingInterval = time.Duration(viper.GetInt64(common.CfgRPCWSPingIntervalSecs)) * time.Second
+
+	if err := startMetrics(monitor); err != nil {
+		return err
+	}
 
 	if viper.GetBool(common.CfgRPCEnabled) {
+		if err := loadAccessConfig(); err != nil {
+			return err
+		}
+		costs := loadMethodCosts()
+		publicGate = chainGates(
+			privateMethods.rejects,
+			apiKeyGate(keys, viper.GetBool(common.CfgAPIKeysRequired)),
+			newRateLimiter(keys, costs).gate(),
+			usageGate(keys, costs))
+
 		httpAddr := viper.GetString(common.CfgRPCHttpAddress)
 		httpPort := viper.GetString(common.CfgRPCHttpPort)
 		httpEndpoint = fmt.Sprintf("%v:%v", httpAddr, httpPort)
-		if err := startHTTP(apis); err != nil {
+		if err := startHTTP(apis, monitor); err != nil {
 			return err
 		}
 
@@ -67,65 +116,106 @@ func StartServers(apis []erpclib.API) error {
 		}
 	}
 
-	return nil
+	return startAuth(apis)
 }
 
-// StopServers stops the http & ws servers
+// StopServers stops the http & ws servers. The endpoints stop accepting connections right away,
+// then the in-flight requests are given up to rpc.shutdownTimeoutSecs to complete.
 func StopServers() error {
-	if httpListener != nil {
-		if err := httpListener.Close(); err != nil {
-			return err
+	shutdownTimeout := time.Duration(viper.GetInt64(common.CfgRPCShutdownTimeoutSecs)) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			logger.Warnf("HTTP endpoint closed before the in-flight requests completed: %v", err)
 		}
-		httpListener = nil
+		httpServer = nil
 		logger.Infof("HTTP endpoint closed")
 	}
 	if httpHandler != nil {
 		httpHandler.Stop()
 		httpHandler = nil
 	}
-	if wsListener != nil {
-		if err := wsListener.Close(); err != nil {
-			return err
-		}
-		wsListener = nil
+	stopAuth(ctx)
+	if wsServer != nil {
+		// The WS connections are hijacked from the http server, so Shutdown only closes the listener
+		wsServer.Shutdown(ctx)
+		wsServer = nil
 		logger.Infof("WS endpoint closed")
 	}
+	if err := drainWSConns(ctx); err != nil {
+		logger.Warnf("WS connections closed before the in-flight requests completed: %v", err)
+	}
 	if wsHandler != nil {
 		wsHandler.Stop()
 		wsHandler = nil
 	}
+	if authHandler != nil {
+		authHandler.Stop()
+		authHandler = nil
+	}
+	stopMetrics(ctx)
 	return nil
 }
 
 // getAPIs returns all the API methods for the RPC interface
-func getAPIs() []erpclib.API {
+func getAPIs(b backend.ScriptBackend, f *follower.Follower, idx *logindex.Index, oracle *gasprice.Oracle) []erpclib.API {
 	publicAPIs := []erpclib.API{
-		netrpc.NewNetRPCService(netNamespace),
-		ethrpc.NewEthRPCService(ethNamespace),
+		netrpc.NewNetRPCService(netNamespace, b),
+		ethrpc.NewEthRPCService(ethNamespace, b, f, idx, oracle),
 		web3rpc.NewWeb3RPCService(web3Namespace),
 		//evmrpc.NewEvmRPCService(evmNamespace),
+		debugrpc.NewDebugRPCService(debugNamespace, b),
 	}
 
 	return publicAPIs
 }
 
-func startHTTP(apis []erpclib.API) (err error) {
-	httpListener, httpHandler, err = erpclib.StartHTTPEndpoint(
-		httpEndpoint, apis, HTTPModules, httpOrigins, httpVirtualHosts, httpTimeouts,
-	)
+// newRPCServer registers the APIs of the given modules
+func newRPCServer(apis []erpclib.API, modules []string) (*erpclib.Server, error) {
+	server := erpclib.NewServer()
+	for _, api := range modulesAPIs(apis, modules) {
+		if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+			return nil, err
+		}
+	}
+	return server, nil
+}
+
+func startHTTP(apis []erpclib.API, monitor *health.Monitor) (err error) {
+	httpHandler, err = newRPCServer(apis, HTTPModules)
 	if err != nil {
 		return err
 	}
+	listener, err := net.Listen("tcp", httpEndpoint)
+	if err != nil {
+		return err
+	}
+	handler := newTimeoutHandler(newHTTPLimitHandler(httpHandler, newCallLimits(), publicGate), requestTimeout)
+	httpServer = erpclib.NewHTTPServer(httpOrigins, httpVirtualHosts, httpTimeouts, handler)
+	httpServer.Handler = monitor.Handler(httpServer.Handler) // the probes are not subject to the virtual hosts
+	go httpServer.Serve(newLimitListener(listener, connSlots))
 
 	logger.Infof("Started RPC server at: %v\n", httpEndpoint)
 	return nil
 }
 
 func startWS(apis []erpclib.API) (err error) {
-	wsListener, wsHandler, err = erpclib.StartWSEndpoint(wsEndpoint, apis, WSModules, wsOrigins, true)
+	wsHandler, err = newRPCServer(apis, WSModules)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", wsEndpoint)
 	if err != nil {
 		return err
 	}
+	wsServer = &http.Server{
+		Handler:     newWSHandler(wsHandler, wsOrigins, newCallLimits(), publicGate, wsPingInterval),
+		ReadTimeout: httpTimeouts.ReadTimeout,
+		IdleTimeout: httpTimeouts.IdleTimeout,
+	}
+	go wsServer.Serve(newLimitListener(listener, connSlots))
 
 	logger.Infof("Started WS server at: %v\n", wsEndpoint)
 	return nil
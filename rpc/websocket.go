@@ -0,0 +1,500 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	erpclib "github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+	"github.com/scripttoken/script-eth-rpc-adaptor/common"
+	"github.com/scripttoken/script-eth-rpc-adaptor/tracing"
+)
+
+const (
+	wsReadBufferSize    = 1024
+	wsWriteBufferSize   = 1024
+	wsPingWriteTimeout  = 5 * time.Second
+	wsDrainPollInterval = 100 * time.Millisecond
+)
+
+var errConnClosed = errors.New("connection closed")
+
+// wsConns keeps track of the open WS connections, so that their in-flight calls can be drained
+var wsConns = struct {
+	sync.Mutex
+	conns map[*wsConn]struct{}
+}{conns: make(map[*wsConn]struct{})}
+
+// newWSHandler upgrades the connections and serves them through the RPC server, with the
+// call limits enforced on each message read from and written to the connection, and the calls
+// rejected by the gate answered. The subscription calls are served through the codec of the
+// connection, the other calls one by one, each with its own context, as over http. The
+// connections are pinged every pingInterval, and closed if no message or pong is received for
+// two intervals.
+func newWSHandler(server *erpclib.Server, allowedOrigins []string, limits callLimits, gate callGate, pingInterval time.Duration) http.Handler {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  wsReadBufferSize,
+		WriteBufferSize: wsWriteBufferSize,
+		CheckOrigin:     originChecker(allowedOrigins),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Debugf("WS upgrade failed: %v", err)
+			return
+		}
+		conn.SetReadLimit(maxRequestContentLength)
+		if pingInterval <= 0 {
+			// The hijacked connection keeps the read deadline set by the http server from its ReadTimeout
+			conn.UnderlyingConn().SetReadDeadline(time.Time{})
+		}
+
+		c := newWSConn(conn, r, newTimeoutHandler(server, requestTimeout), limits, chainGates(gate), pingInterval)
+		wsConns.Lock()
+		wsConns.conns[c] = struct{}{}
+		wsConns.Unlock()
+		wsConnections.Inc()
+		defer func() {
+			wsConns.Lock()
+			delete(wsConns.conns, c)
+			wsConns.Unlock()
+			wsConnections.Dec()
+			c.abandonCalls()
+		}()
+
+		if pingInterval > 0 {
+			go c.pingLoop()
+		}
+		server.ServeCodec(erpclib.NewFuncCodec(c, c.writeJSON, c.readJSON), erpclib.OptionMethodInvocation|erpclib.OptionSubscriptions)
+	})
+}
+
+// originChecker accepts the requests without an Origin header, and the ones from the allowed origins
+func originChecker(allowedOrigins []string) func(r *http.Request) bool {
+	origins := make(map[string]bool)
+	for _, origin := range allowedOrigins {
+		origins[strings.ToLower(origin)] = true
+	}
+	return func(r *http.Request) bool {
+		origin := strings.ToLower(r.Header.Get("Origin"))
+		if origins["*"] || origin == "" || origins[origin] {
+			return true
+		}
+		logger.Warnf("Rejected WS connection from origin %v", origin)
+		return false
+	}
+}
+
+// wsConn sits between a websocket connection and the codec of the RPC server. The messages
+// are served on their own goroutine, either by the RPC server through the codec or by serve,
+// so the reads block while maxConcurrentCalls calls are in flight, until their responses are written.
+type wsConn struct {
+	conn         *websocket.Conn
+	req          *http.Request   // the upgrade request, identifying the client to the gate
+	handler      http.Handler    // the RPC server, serving the calls which need no subscription
+	traceCtx     context.Context // carries the traceparent of the upgrade request, the parent of the call spans
+	limits       callLimits
+	gate         callGate
+	pingInterval time.Duration
+
+	writeMu sync.Mutex // the codec and the limit errors both write to the connection
+
+	slots     chan struct{}
+	pendingMu sync.Mutex
+	pending   map[string]int // number of slots held by the calls in flight, by id
+	calls     map[string][]*inFlightCall
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newWSConn(conn *websocket.Conn, req *http.Request, handler http.Handler, limits callLimits, gate callGate, pingInterval time.Duration) *wsConn {
+	c := &wsConn{
+		conn:         conn,
+		req:          req,
+		handler:      handler,
+		traceCtx:     tracing.Extract(context.Background(), req.Header),
+		limits:       limits,
+		gate:         gate,
+		pingInterval: pingInterval,
+		slots:        make(chan struct{}, limits.maxConcurrentCalls),
+		pending:      make(map[string]int),
+		calls:        make(map[string][]*inFlightCall),
+		closed:       make(chan struct{}),
+	}
+	conn.SetPongHandler(func(string) error {
+		c.extendReadDeadline()
+		return nil
+	})
+	return c
+}
+
+// extendReadDeadline gives the peer two ping intervals to send a message or a pong
+func (c *wsConn) extendReadDeadline() {
+	if c.pingInterval > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval))
+	}
+}
+
+func (c *wsConn) pingLoop() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsPingWriteTimeout)); err != nil {
+				logger.Debugf("Failed to ping %v: %v", c.conn.RemoteAddr(), err)
+				c.Close()
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// inFlight returns the number of calls read from the connection which have not been answered yet
+func (c *wsConn) inFlight() int {
+	return len(c.slots)
+}
+
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.conn.Close()
+}
+
+func (c *wsConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// readJSON reads the next message which is within the limits, the others are answered with an error
+func (c *wsConn) readJSON(v interface{}) error {
+	for {
+		c.extendReadDeadline()
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		msgs, isBatch := parseBatch(data)
+		if isBatch && c.limits.batchRequestLimit > 0 && len(msgs) > c.limits.batchRequestLimit {
+			logger.Debugf("Rejected a batch of %v requests from %v", len(msgs), c.conn.RemoteAddr())
+			if err := c.write(errorResponse(nullID, errCodeInvalidRequest, errMsgBatchTooLarge)); err != nil {
+				return err
+			}
+			continue
+		}
+		if res, rejected := c.reject(data, msgs, isBatch); rejected {
+			if err := c.write(res); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The calls of a batch are served one after the other, the batch takes a single slot
+		id := messageID(data)
+		if isBatch {
+			id = nil
+			for _, msg := range msgs {
+				if id = messageID(msg); id != nil {
+					break
+				}
+			}
+		}
+		if id != nil {
+			if err := c.acquire(id); err != nil {
+				return err
+			}
+		}
+		if (!isBatch || len(msgs) > 0) && !needsSubscription(data, msgs, isBatch) {
+			go c.serve(data, msgs, isBatch, id)
+			continue
+		}
+		if isBatch {
+			for _, msg := range msgs {
+				c.startCall(msg)
+			}
+		} else {
+			c.startCall(data)
+		}
+
+		return json.Unmarshal(data, v)
+	}
+}
+
+// needsSubscription tells whether the message, or one of the calls of the batch, subscribes or
+// unsubscribes, which only the codec of the connection supports
+func needsSubscription(data []byte, msgs []json.RawMessage, isBatch bool) bool {
+	if !isBatch {
+		msgs = []json.RawMessage{data}
+	}
+	for _, msg := range msgs {
+		method := messageHeader(msg).Method
+		if strings.HasSuffix(method, "_subscribe") || strings.HasSuffix(method, "_unsubscribe") {
+			return true
+		}
+	}
+	return false
+}
+
+// serve answers a message which needs no subscription, and frees the slot taken for it
+func (c *wsConn) serve(data []byte, msgs []json.RawMessage, isBatch bool, id json.RawMessage) {
+	defer c.release(id)
+
+	if !isBatch {
+		res, call := c.serveCall(data)
+		if len(res) > 0 {
+			res = c.limits.limitResponses([]json.RawMessage{res})[0]
+		}
+		call.finish(res)
+		if len(res) > 0 {
+			c.write(res)
+		}
+		return
+	}
+
+	// The calls of a batch are served one after the other, as by the codec
+	responses := make([]json.RawMessage, 0, len(msgs))
+	calls := make([]*inFlightCall, 0, len(msgs))
+	for _, msg := range msgs {
+		res, call := c.serveCall(msg)
+		if len(res) == 0 { // no response to the notifications
+			call.finish(nil)
+			continue
+		}
+		responses = append(responses, res)
+		calls = append(calls, call)
+	}
+	responses = c.limits.limitResponses(responses)
+	for i, call := range calls {
+		call.finish(responses[i])
+	}
+	if len(responses) == 0 {
+		return
+	}
+	res, err := json.Marshal(responses)
+	if err != nil {
+		return
+	}
+	c.write(res)
+}
+
+// serveCall serves a single call through the http handler of the RPC server, with a context
+// carrying the span of the call and a slot for the data of its error
+func (c *wsConn) serveCall(msg json.RawMessage) (json.RawMessage, *inFlightCall) {
+	ctx, call := startCall(c.traceCtx, msg, "ws")
+	ctx = common.ContextWithErrorData(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(msg))
+	if err != nil {
+		return errorResponse(messageID(msg), errCodeInvalidRequest, err.Error()), call
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, key := range []string{"User-Agent", "Origin"} {
+		if value := c.req.Header.Get(key); value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	req.RemoteAddr = c.req.RemoteAddr
+	req.Host = c.req.Host
+
+	rec := newResponseRecorder()
+	c.handler.ServeHTTP(rec, req)
+	if rec.status != http.StatusOK {
+		return errorResponse(messageID(msg), errCodeInvalidRequest, strings.TrimSpace(rec.body.String())), call
+	}
+	res := bytes.TrimSpace(rec.body.Bytes())
+	if len(res) == 0 {
+		return nil, call
+	}
+	return withErrorData(ctx, res), call
+}
+
+// reject answers the messages rejected by the gate. A batch with such a call is rejected as a
+// whole, since it cannot be answered partly here and partly by the RPC server.
+func (c *wsConn) reject(data []byte, msgs []json.RawMessage, isBatch bool) (json.RawMessage, bool) {
+	if !isBatch {
+		res, rejected := c.gate(c.req, data)
+		if rejected {
+			_, call := startCall(c.traceCtx, data, "ws")
+			call.finish(res)
+		}
+		return res, rejected
+	}
+
+	responses := make([]json.RawMessage, len(msgs))
+	rejected := false
+	for i, msg := range msgs {
+		var callRejected bool
+		if responses[i], callRejected = c.gate(c.req, msg); callRejected {
+			rejected = true
+		} else {
+			responses[i] = errorResponse(messageID(msg), errCodeInvalidRequest, "batch rejected along with a call which cannot be served")
+		}
+	}
+	if !rejected {
+		return nil, false
+	}
+	for i, msg := range msgs {
+		_, call := startCall(c.traceCtx, msg, "ws")
+		call.finish(responses[i])
+	}
+	res, _ := json.Marshal(responses)
+	return res, true
+}
+
+// writeJSON writes a response or a notification, once the response size limit is enforced
+func (c *wsConn) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if msgs, isBatch := parseBatch(data); isBatch {
+		msgs = c.limits.limitResponses(msgs)
+		for _, msg := range msgs {
+			c.finishCall(msg)
+		}
+		for _, msg := range msgs {
+			if c.release(messageID(msg)) {
+				break
+			}
+		}
+		if data, err = json.Marshal(msgs); err != nil {
+			return err
+		}
+	} else {
+		id := messageID(data)
+		if id == nil && c.limits.maxResponseBytes > 0 && len(data) > c.limits.maxResponseBytes {
+			logger.Warnf("Dropped a notification of %v bytes to %v", len(data), c.conn.RemoteAddr())
+			return nil
+		}
+		data = c.limits.limitResponses([]json.RawMessage{data})[0]
+		c.finishCall(data)
+		c.release(id)
+	}
+
+	return c.write(data)
+}
+
+func (c *wsConn) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// acquire takes a slot for the call with the given id, waiting for one to be released if needed
+func (c *wsConn) acquire(id json.RawMessage) error {
+	select {
+	case c.slots <- struct{}{}:
+	case <-c.closed:
+		return errConnClosed
+	}
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	c.pending[string(id)]++
+	return nil
+}
+
+// release frees the slot of the call with the given id, it returns false if no call with this id is in flight
+func (c *wsConn) release(id json.RawMessage) bool {
+	if id == nil {
+		return false
+	}
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if c.pending[string(id)] == 0 {
+		return false
+	}
+	c.pending[string(id)]--
+	if c.pending[string(id)] == 0 {
+		delete(c.pending, string(id))
+	}
+	<-c.slots
+	return true
+}
+
+// startCall measures a call served through the codec until its response is written, the
+// notifications are recorded right away since they get no response. The RPC server serves these
+// calls with the context of the connection, so the upstream calls of the subscriptions are not
+// part of their traces.
+func (c *wsConn) startCall(msg json.RawMessage) {
+	_, call := startCall(c.traceCtx, msg, "ws")
+	id := messageID(msg)
+	if id == nil {
+		call.finish(nil)
+		return
+	}
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	c.calls[string(id)] = append(c.calls[string(id)], call)
+}
+
+// finishCall records the call answered by the given response
+func (c *wsConn) finishCall(res json.RawMessage) {
+	id := messageID(res)
+	if id == nil {
+		return
+	}
+
+	c.pendingMu.Lock()
+	calls := c.calls[string(id)]
+	if len(calls) == 0 {
+		c.pendingMu.Unlock()
+		return
+	}
+	call := calls[0]
+	if len(calls) == 1 {
+		delete(c.calls, string(id))
+	} else {
+		c.calls[string(id)] = calls[1:]
+	}
+	c.pendingMu.Unlock()
+
+	call.finish(res)
+}
+
+// abandonCalls drops the calls left unanswered when the connection closed
+func (c *wsConn) abandonCalls() {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	for id, calls := range c.calls {
+		for _, call := range calls {
+			call.abandon()
+		}
+		delete(c.calls, id)
+	}
+}
+
+// drainWSConns waits until the calls in flight on the open WS connections are answered
+func drainWSConns(ctx context.Context) error {
+	ticker := time.NewTicker(wsDrainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		inFlight := 0
+		wsConns.Lock()
+		for c := range wsConns.conns {
+			inFlight += c.inFlight()
+		}
+		wsConns.Unlock()
+		if inFlight == 0 {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NewOTLPExporter exports to the OTLP/HTTP collector at endpoint, such as http://127.0.0.1:4318.
+// The spans are posted to <endpoint>/v1/traces with the given headers.
+func NewOTLPExporter(endpoint string, headers map[string]string) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	options := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(strings.TrimSuffix(u.Path, "/") + "/v1/traces"),
+		otlptracehttp.WithHeaders(headers),
+	}
+	switch u.Scheme {
+	case "http":
+		options = append(options, otlptracehttp.WithInsecure())
+	case "https":
+	default:
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expecting an http or https URL", endpoint)
+	}
+	return otlptracehttp.New(context.Background(), options...)
+}
+
+// NewWriterExporter exports to w as lines of JSON, w is closed with the tracer if it is an io.Closer
+func NewWriterExporter(w io.Writer) (sdktrace.SpanExporter, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, err
+	}
+	return &writerExporter{Exporter: exporter, w: w}, nil
+}
+
+type writerExporter struct {
+	*stdouttrace.Exporter
+	w io.Writer
+}
+
+func (e *writerExporter) Shutdown(ctx context.Context) error {
+	err := e.Exporter.Shutdown(ctx)
+	if closer, ok := e.w.(io.Closer); ok {
+		closer.Close()
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger *log.Entry = log.WithFields(log.Fields{"prefix": "tracing"})
+
+// shutdownTimeout bounds the export of the spans queued when the tracer stops
+const shutdownTimeout = 10 * time.Second
+
+// Tracer samples the spans and exports them in batches with the OTel SDK. The spans are queued
+// when they end, and dropped if the exporter cannot keep up rather than slowing the requests down.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+
+	// Life cycle
+	wg *sync.WaitGroup
+}
+
+// NewTracer creates a tracer sampling sampleRatio of the traces started by this process, a ratio
+// of 1 samples them all. The traces started by a caller follow the decision of the caller.
+func NewTracer(exporter sdktrace.SpanExporter, serviceName string, sampleRatio float64) *Tracer {
+	return &Tracer{
+		provider: sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+			sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+				semconv.ServiceNameKey.String(serviceName))),
+		),
+		wg: &sync.WaitGroup{},
+	}
+}
+
+// Start makes the tracer the one of StartSpan.
+func (t *Tracer) Start() {
+	otel.SetTracerProvider(t.provider)
+}
+
+// Stop disables tracing and flushes the queued spans without blocking. The spans started
+// before still get exported when they end.
+func (t *Tracer) Stop() {
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := t.provider.Shutdown(ctx); err != nil {
+			logger.Warnf("Failed to export the queued spans: %v", err)
+		}
+	}()
+}
+
+// Wait blocks until the queued spans are exported.
+func (t *Tracer) Wait() {
+	t.wg.Wait()
+}
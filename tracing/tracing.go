@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the spans started by the adaptor
+const instrumentationName = "github.com/scripttoken/script-eth-rpc-adaptor"
+
+// propagator carries the span context across processes in the traceparent header, as per W3C
+// trace context. It is used whether tracing is enabled or not, so that the adaptor forwards the
+// trace of its callers to the Script node either way.
+var propagator = propagation.TraceContext{}
+
+// StartSpan starts a span, the child of the span carried by ctx if any. The returned context
+// carries the new span. The span does not record anything while tracing is disabled.
+func StartSpan(ctx context.Context, name string, kind trace.SpanKind, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(kind), trace.WithAttributes(attributes...))
+}
+
+// SetError marks the span as failed with the given error, a nil error is ignored
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Inject sets the traceparent header to the span context carried by ctx, if any
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract returns a context carrying the span context of the traceparent header, the spans
+// started from it continue the trace of the caller. ctx is returned as is if the header is
+// missing or malformed.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}